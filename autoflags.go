@@ -0,0 +1,280 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
+)
+
+// flagParser is the go-flags parser of a config together with the flags that AutoFlags generates for it
+type flagParser struct {
+	*flags.Parser
+	cfg  reflect.Value
+	auto []autoGroup
+	// config is the group of the config file flag
+	config *flags.Group
+}
+
+// autoGroup holds the generated flags of a group. go-flags only creates options for tagged fields, and its AddOption
+// cannot set up an option for a field, so the untagged fields get long tags in a struct of their own that is a
+// subgroup of their group. Its values are copied from the config before every parse and back after it.
+type autoGroup struct {
+	group  *flags.Group
+	value  reflect.Value
+	fields []field
+}
+
+// newFlagParser returns the parser of cfg, with AutoFlags it also has a long flag for every field without flag tags
+func newFlagParser(copts *confOptions, cfg any, flagOpts flags.Options) (*flagParser, error) {
+	p := &flagParser{Parser: flags.NewParser(cfg, flagOpts), cfg: reflect.ValueOf(cfg)}
+	p.NamespaceDelimiter = copts.delimiter
+	if !copts.autoFlags || len(p.Groups()) == 0 {
+		return p, nil
+	}
+
+	t := p.cfg.Elem().Type()
+	b := &autoFlagBuilder{p: p, delimiter: copts.delimiter, taken: make(map[string]bool), seen: make(map[reflect.Type]bool)}
+	b.collect(t, "")
+	if err := b.group(p.Groups()[0], t, nil, "", ""); err != nil {
+		return nil, errors.Wrap(err, "failed to add the flags of AutoFlags")
+	}
+	return p, nil
+}
+
+// plainParser returns a parser of cfg without generated flags, for the decoders that only see the config
+func plainParser(cfg any) *flagParser {
+	return &flagParser{Parser: flags.NewParser(cfg, flags.None), cfg: reflect.ValueOf(cfg)}
+}
+
+// ParseArgs parses args like the parser of go-flags and keeps the fields of the generated flags in sync with the config
+func (p *flagParser) ParseArgs(args []string) ([]string, error) {
+	for _, g := range p.auto {
+		for i, f := range g.fields {
+			v, ok := f.get(p.cfg)
+			if !ok {
+				v = reflect.Zero(f.typ)
+			}
+			g.value.Elem().Field(i).Set(v)
+		}
+	}
+
+	rest, err := p.Parser.ParseArgs(args)
+
+	for _, g := range p.auto {
+		options := g.group.Options()
+		for i, f := range g.fields {
+			// a field behind a nil pointer is only allocated if its flag got a value
+			if _, ok := f.get(p.cfg); ok || options[i].IsSet() {
+				f.set(p.cfg, g.value.Elem().Field(i))
+			}
+		}
+	}
+	return rest, err
+}
+
+// autoOptions maps the generated options onto the paths of their fields
+func (p *flagParser) autoOptions(m map[*flags.Option]string) {
+	for _, g := range p.auto {
+		for i, o := range g.group.Options() {
+			m[o] = g.fields[i].path
+		}
+	}
+}
+
+// autoFlagBuilder finds the fields that go-flags would leave out and gives them long flags.
+// The long name is the yaml key of the field, prefixed with the yaml keys of the untagged structs around it,
+// and go-flags adds the namespaces of the groups. Names that an explicit long tag already uses are skipped.
+type autoFlagBuilder struct {
+	p         *flagParser
+	delimiter string
+	// taken holds the long names with namespaces that are in use
+	taken map[string]bool
+	// seen holds the struct types that are being walked, a struct that contains itself is not walked again
+	seen map[reflect.Type]bool
+}
+
+// collect records the explicit long names
+func (b *autoFlagBuilder) collect(t reflect.Type, namespace string) {
+	if b.seen[t] {
+		return
+	}
+	b.seen[t] = true
+	defer delete(b.seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous || sf.Tag.Get("no-flag") != "" {
+			continue
+		}
+		if long := sf.Tag.Get("long"); long != "" {
+			b.taken[namespace+long] = true
+		}
+
+		ft := indirectType(sf.Type)
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		switch {
+		case sf.Tag.Get("group") != "":
+			b.collect(ft, b.namespace(namespace, sf))
+		case sf.Tag.Get("command") != "", sf.Tag.Get("positional-args") != "":
+		default:
+			b.collect(ft, namespace)
+		}
+	}
+}
+
+func (b *autoFlagBuilder) namespace(namespace string, sf reflect.StructField) string {
+	if ns := sf.Tag.Get("namespace"); ns != "" {
+		return namespace + ns + b.delimiter
+	}
+	return namespace
+}
+
+func (b *autoFlagBuilder) name(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + b.delimiter + key
+}
+
+// group adds the generated flags of the fields of the struct type t, which is the struct of the group g at index
+// in the config. It walks the subgroups in the same order as go-flags created them.
+func (b *autoFlagBuilder) group(g *flags.Group, t reflect.Type, index []int, path, namespace string) error {
+	var fields []reflect.StructField
+	var targets []field
+	groups := g.Groups()
+
+	var scan func(t reflect.Type, index []int, path, prefix string, settable bool) error
+	scan = func(t reflect.Type, index []int, path, prefix string, settable bool) error {
+		if b.seen[t] {
+			return nil
+		}
+		b.seen[t] = true
+		defer delete(b.seen, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous || sf.Tag.Get("no-flag") != "" {
+				continue
+			}
+			if sf.Tag.Get("command") != "" || sf.Tag.Get("positional-args") != "" {
+				continue
+			}
+			idx := append(append([]int{}, index...), i)
+			fieldPath := joinPath(path, sf.Name)
+			ft := indirectType(sf.Type)
+
+			if sf.Tag.Get("group") != "" {
+				if ft.Kind() == reflect.Struct && len(groups) > 0 {
+					sub := groups[0]
+					groups = groups[1:]
+					if err := b.group(sub, ft, idx, fieldPath, b.namespace(namespace, sf)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if sf.PkgPath != "" {
+				// go-flags scans the tagged fields of embedded unexported structs, which reflection cannot set
+				if ft.Kind() == reflect.Struct {
+					if err := scan(ft, idx, path, prefix, false); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if sf.Tag.Get("long") != "" || sf.Tag.Get("short") != "" || sf.Tag.Get("ini-name") != "" {
+				continue
+			}
+
+			key, inline, skip := yamlFieldName(sf)
+			if skip {
+				continue
+			}
+			if inline {
+				if ft.Kind() == reflect.Struct {
+					if err := scan(ft, idx, fieldPath, prefix, settable); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			name := b.name(prefix, key)
+			if st, ok := nestedStruct(sf); ok {
+				if err := scan(st, idx, fieldPath, name, settable); err != nil {
+					return err
+				}
+				continue
+			}
+			if !settable || !flagValueType(sf.Type) || b.taken[namespace+name] {
+				continue
+			}
+			b.taken[namespace+name] = true
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("Field%d", len(fields)),
+				Type: sf.Type,
+				Tag:  reflect.StructTag(fmt.Sprintf(`%s long:"%s"`, sf.Tag, name)),
+			})
+			targets = append(targets, field{path: fieldPath, index: idx, typ: sf.Type})
+		}
+		return nil
+	}
+	if err := scan(t, index, path, "", true); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	st, err := structOf(fields)
+	if err != nil {
+		return err
+	}
+	value := reflect.New(st)
+	sub, err := g.AddGroup(g.ShortDescription, g.LongDescription, value.Interface())
+	if err != nil {
+		return err
+	}
+	b.p.auto = append(b.p.auto, autoGroup{group: sub, value: value, fields: targets})
+	return nil
+}
+
+// structOf is reflect.StructOf with an error in place of its panics
+func structOf(fields []reflect.StructField) (t reflect.Type, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("%v", r)
+		}
+	}()
+	return reflect.StructOf(fields), nil
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// flagValueType reports whether go-flags can set a value of type t from a string
+func flagValueType(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(flagUnmarshalerType) || t.Implements(flagUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Ptr, reflect.Slice:
+		return flagValueType(t.Elem())
+	case reflect.Map:
+		return flagValueType(t.Key()) && flagValueType(t.Elem())
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package conf_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/require"
+)
+
+type autoTLSOptions struct {
+	Cert string `yaml:"cert" description:"certificate file"`
+	Key  string `yaml:"key"`
+}
+
+type autoServerOptions struct {
+	TLS     autoTLSOptions `yaml:"tls"`
+	Port    int            `yaml:"port" default:"80"`
+	Hosts   []string       `yaml:"hosts"`
+	Timeout time.Duration  `yaml:"timeout"`
+}
+
+type autoDBOptions struct {
+	Host string `yaml:"host"`
+	User string `long:"user" yaml:"user"`
+}
+
+type autoOptions struct {
+	Name     string             `yaml:"name"`
+	Debug    bool               `yaml:"debug"`
+	Labels   map[string]string  `yaml:"labels"`
+	Server   autoServerOptions  `yaml:"server"`
+	Backup   *autoServerOptions `yaml:"backup"`
+	DB       autoDBOptions      `group:"db" namespace:"db"`
+	Explicit string             `long:"server-tls-key" yaml:"explicit"`
+	Skipped  string             `yaml:"-"`
+	Created  time.Time          `yaml:"created"`
+	secret   string
+}
+
+func Test_Load_AutoFlags(t *testing.T) {
+	cfg, report, err := conf.LoadWithReport[autoOptions](
+		conf.AutoFlags(),
+		conf.Args([]string{
+			"--name=app",
+			"--debug",
+			"--labels=env:prod",
+			"--server-tls-cert=cert.pem",
+			"--server-hosts=h1", "--server-hosts=h2",
+			"--server-timeout=2s",
+			"--server-tls-key=explicit",
+			"--backup-port=81",
+			"--db-host=db1",
+			"--db-user=admin",
+		}),
+		conf.WithFlagOpts(flags.None),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "app", cfg.Name)
+	require.True(t, cfg.Debug)
+	require.Equal(t, map[string]string{"env": "prod"}, cfg.Labels)
+	require.Equal(t, autoServerOptions{
+		TLS:     autoTLSOptions{Cert: "cert.pem"},
+		Port:    80,
+		Hosts:   []string{"h1", "h2"},
+		Timeout: 2 * time.Second,
+	}, cfg.Server)
+	require.Equal(t, 81, cfg.Backup.Port)
+	require.Equal(t, autoDBOptions{Host: "db1", User: "admin"}, cfg.DB)
+	require.Equal(t, "explicit", cfg.Explicit)
+
+	field, ok := report.Field("Server.TLS.Cert")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Flags, Name: "--server-tls-cert"}, field.Origin)
+
+	field, ok = report.Field("Server.Port")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Defaults, Name: "--server-port"}, field.Origin)
+}
+
+func Test_Load_AutoFlags_Delimiter(t *testing.T) {
+	cfg, err := conf.Load[autoOptions](
+		conf.AutoFlags(),
+		conf.Delimiter("."),
+		conf.Args([]string{"--server.tls.key=key.pem", "--db.host=db1"}),
+		conf.WithFlagOpts(flags.None),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "key.pem", cfg.Server.TLS.Key)
+	require.Equal(t, "db1", cfg.DB.Host)
+}
+
+func Test_Load_AutoFlags_Off(t *testing.T) {
+	_, err := conf.Load[autoOptions](conf.Args([]string{"--name=app"}), conf.WithFlagOpts(flags.None))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown flag `name'")
+
+	_, err = conf.Load[autoOptions](conf.AutoFlags(), conf.Args([]string{"--created=now"}), conf.WithFlagOpts(flags.None))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown flag `created'")
+}
+
+func Test_Load_AutoFlags_EnvPrefix(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("MYAPP_SERVER_TLS_CERT", "env.pem")
+	os.Setenv("MYAPP_DB_HOST", "db2")
+
+	cfg, err := conf.Load[autoOptions](conf.AutoFlags(), conf.EnvPrefix("MYAPP"), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, "env.pem", cfg.Server.TLS.Cert)
+	require.Equal(t, "db2", cfg.DB.Host)
+}
+
+func Test_Load_AutoFlags_Help(t *testing.T) {
+	if os.Getenv("CONF_TEST_AUTO_FLAGS_HELP") == "1" {
+		_, _ = conf.Load[autoOptions](conf.AutoFlags(), conf.Args([]string{"--help"}))
+		os.Exit(1)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_Load_AutoFlags_Help$")
+	cmd.Env = append(os.Environ(), "CONF_TEST_AUTO_FLAGS_HELP=1")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	require.Contains(t, string(out), "--server-tls-cert=")
+	require.Contains(t, string(out), "certificate file")
+	require.Contains(t, string(out), "--server-port=")
+	require.Contains(t, string(out), "--db-host=")
+}
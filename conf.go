@@ -1,11 +1,17 @@
 package conf
 
 import (
+	"bytes"
 	stderr "errors"
 	"io"
 	"io/fs"
+	"net/http"
 	"os"
+	pathpkg "path"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/go-playground/validator/v10"
@@ -16,28 +22,73 @@ import (
 var validate = validator.New()
 
 func Load[T any](opts ...ConfOption) (*T, error) {
+	cfg, _, err := LoadWithReport[T](opts...)
+	return cfg, err
+}
+
+// LoadWithReport loads the config like Load and also reports where the value of every field came from
+func LoadWithReport[T any](opts ...ConfOption) (*T, *Report, error) {
 	cfg := new(T)
-	cfgDefaults := new(T)
-	var err error
 
+	report, _, err := load(cfg, newConfOptions(opts))
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, report, nil
+}
+
+func newConfOptions(opts []ConfOption) *confOptions {
 	copts := &confOptions{
 		paths:        nil,
 		args:         os.Args[1:],
 		delimiter:    "-",
 		noValidation: false,
-		decoders:     DefaultDecoders,
+		decoders:     make(map[string]DecoderFunc),
 		flagOpts:     flags.Default,
+		pollInterval: time.Second,
+		stdin:        &pathSource{r: os.Stdin},
+		urls:         make(map[string]*pathSource),
+		httpClient:   http.DefaultClient,
+		httpHeader:   make(http.Header),
+		httpTimeout:  30 * time.Second,
+		consulWait:   5 * time.Minute,
 	}
 
 	for _, opt := range opts {
 		opt.apply(copts)
 	}
+	return copts
+}
+
+// layer is a single source loaded into its own copy of the config
+type layer struct {
+	value reflect.Value
+	// set holds the origin of every field the source assigned
+	set map[string]Origin
+	// mergeMaps is set for config files, whose map keys add up to the map of the sources before them like the keys
+	// of two YAML documents decoded into the same map. The other sources replace maps as a whole, like go-flags does.
+	mergeMaps bool
+}
+
+// load merges all the sources into cfg and returns the provenance report and the config file paths it considered
+func load(cfg any, copts *confOptions) (*Report, []configPath, error) {
+	v := reflect.ValueOf(cfg)
+	t := v.Elem().Type()
+	fields := fieldsOf(t)
+
+	if copts.err != nil {
+		return nil, nil, copts.err
+	}
+
+	env, err := loadEnvFiles(copts)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Step 1:
-	// 	load the defaults
 	// 	obtain the config file paths
 	// 	handle the Help message
-	paths, err := mergeDefaults(cfgDefaults, copts)
+	paths, err := parseConfigPaths(copts, t, env)
 	if err != nil {
 		flagsErr := new(flags.Error)
 		if errors.As(err, &flagsErr) {
@@ -45,88 +96,225 @@ func Load[T any](opts ...ConfOption) (*T, error) {
 				os.Exit(0)
 			}
 		}
-		return nil, errors.Wrap(err, "failed to parse command line args")
+		return nil, nil, errors.Wrap(err, "failed to parse command line args")
 	}
 
 	// Step 2:
-	// 	override with values from the config files
-	_, err = mergeConfigFiles(copts, cfg, append(copts.paths, paths...)...)
+	// 	load the defaults, which are also the starting point of the merge
+	defaults, err := loadFlags(copts, t, env, Defaults)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Step 3:
-	// 	create a parser that does not add default values
-	// 	override with values from flags + env variables
-	err = mergeWithoutDefaults(cfg, copts)
+	// 	resolve the config file paths
+	paths = append(append([]configPath{}, copts.paths...), paths...)
+	paths, err = resolvePaths(copts, paths)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Step 4: Merge defaults
-	// 	override the empty values with defaults
-	//  use a custom transformer to avoid map Options containing the default values if they are set in a config file
-	err = mergo.Merge(cfg, cfgDefaults, mergo.WithTransformers(mapDefaultsTransformer{}))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to merge defaults")
+	// Step 4:
+	// 	load every source into copies of its own, in the order of the sources
+	// 	the env variables and the flags are loaded by parsers that do not add default values
+	lc := &loadContext{copts: copts, t: t, fields: fields, env: env, paths: paths, defaults: defaults}
+	var layers []*layer
+	for _, s := range copts.sourceOrder() {
+		ls, err := sourceLayers(lc, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, ls...)
+	}
+
+	// Step 5:
+	// 	start from the defaults, or from no defaults if the sources leave them out, so that map options that nothing sets are empty instead of nil
+	// 	override the config with the fields each layer set, in order
+	// 	fields that were set explicitly win over the defaults even if they hold the zero value
+	base := defaults.value
+	if !copts.uses(Defaults) {
+		// a parser without any layer still makes the maps empty
+		base = reflect.New(t)
+		if _, _, err := parseFlags(base.Interface(), copts, copts.flagOpts&^flags.PrintErrors, env); err != nil {
+			return nil, nil, err
+		}
+	}
+	v.Elem().Set(base.Elem())
+	m := newMerger(fields)
+	for _, l := range layers {
+		m.apply(v, l)
+	}
+
+	// Step 6:
+	// 	replace the secret references with the secrets, the report keeps the references
+	report := m.report(v)
+	if copts.resolvers != nil {
+		resolvers := defaultResolvers(env)
+		for scheme, r := range copts.resolvers {
+			resolvers[scheme] = r
+		}
+		if err := resolveSecrets(v, fields, resolvers); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to resolve secrets")
+		}
 	}
 
 	if !copts.noValidation {
 		err = validate.Struct(cfg)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to validate config")
+			return nil, nil, errors.Wrap(err, "failed to validate config")
 		}
 	}
 
-	return cfg, nil
+	return report, append(paths, copts.envFiles...), nil
 }
 
-type mapDefaultsTransformer struct {
+// merger copies the fields that each layer set onto the config and keeps track of their origins
+type merger struct {
+	fields  []field
+	reports []FieldReport
 }
 
-func (mapDefaultsTransformer) Transformer(t reflect.Type) func(dst, src reflect.Value) error {
-	// use a custom transformer only for maps
-	if t.Kind() != reflect.Map {
-		return nil
+func newMerger(fields []field) *merger {
+	reports := make([]FieldReport, len(fields))
+	for i, f := range fields {
+		reports[i].Path = f.path
 	}
-	return func(dst, src reflect.Value) error {
-		// only merge if dst is empty
-		if dst.IsNil() || len(dst.MapKeys()) == 0 && dst.CanSet() {
-			dst.Set(src)
+	return &merger{fields: fields, reports: reports}
+}
+
+func (m *merger) apply(dst reflect.Value, l *layer) {
+	for i, f := range m.fields {
+		origin, ok := l.set[f.path]
+		if !ok {
+			continue
 		}
-		return nil
+		x, ok := f.get(l.value)
+		if !ok {
+			continue
+		}
+		if prev := m.reports[i].Origin.Layer; l.mergeMaps && x.Kind() == reflect.Map && prev != 0 && prev != Defaults {
+			if cur, ok := f.get(dst); ok && !cur.IsNil() && !x.IsNil() {
+				f.set(dst, mergeMap(cur, x))
+				m.merge(i, Setting{Origin: origin, Value: x.Interface()})
+				continue
+			}
+		}
+		f.set(dst, x)
+		m.record(i, Setting{Origin: origin, Value: x.Interface()})
 	}
 }
 
+func (m *merger) record(i int, s Setting) {
+	r := &m.reports[i]
+	if r.Origin.Layer != 0 {
+		r.Overridden = append(r.Overridden, r.Setting)
+	}
+	r.Setting = s
+}
+
+// merge records a layer whose map keys were added to the map of the layers before it
+func (m *merger) merge(i int, s Setting) {
+	r := &m.reports[i]
+	r.Merged = append(r.Merged, r.Setting)
+	r.Setting = s
+}
+
+// mergeMap returns a new map with the keys of a and b, b wins for the keys that both have.
+// The maps of the layers stay as they are, the report holds them.
+func mergeMap(a, b reflect.Value) reflect.Value {
+	merged := reflect.MakeMapWithSize(a.Type(), a.Len()+b.Len())
+	for _, m := range []reflect.Value{a, b} {
+		iter := m.MapRange()
+		for iter.Next() {
+			merged.SetMapIndex(iter.Key(), iter.Value())
+		}
+	}
+	return merged
+}
+
+func (m *merger) report(dst reflect.Value) *Report {
+	for i, f := range m.fields {
+		if x, ok := f.get(dst); ok {
+			m.reports[i].Value = x.Interface()
+		}
+	}
+	return &Report{Fields: m.reports}
+}
+
 type fileConfig struct {
 	ConfigFilePaths []string `long:"conf" description:"config file paths"`
 }
 
-func parseFlags(cfg any, defaults bool, copts *confOptions) ([]configPath, error) {
+func parseFlags(cfg any, copts *confOptions, flagOpts flags.Options, env map[string]dotenvVar, layers ...Layer) (*flagParser, []configPath, error) {
+	uses := func(l Layer) bool {
+		for _, layer := range layers {
+			if layer == l {
+				return true
+			}
+		}
+		return false
+	}
+
 	cfgF := &fileConfig{}
-	p := flags.NewParser(cfg, copts.flagOpts)
-	p.NamespaceDelimiter = copts.delimiter
+	p, err := newFlagParser(copts, cfg, flagOpts)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if copts.configFlagOption != nil {
 		g, err := p.AddGroup("Config", "", cfgF)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to add config group")
+			return nil, nil, errors.Wrap(err, "failed to add config group")
 		}
+		p.config = g
 		err = mergo.Merge(g.Options()[0], copts.configFlagOption, mergo.WithOverride)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to merge config flag option")
+			return nil, nil, errors.Wrap(err, "failed to merge config flag option")
 		}
 	}
 
-	if !defaults {
-		eachOption(p.Command, func(c *flags.Command, g *flags.Group, o *flags.Option) {
+	derived := derivedEnvKeys(copts, p)
+	var envErr error
+	eachOption(p.Command, func(c *flags.Command, g *flags.Group, o *flags.Option) {
+		if !uses(Defaults) {
 			o.Default = []string{}
-		})
+		}
+		if !uses(Env) {
+			o.EnvDefaultKey = ""
+		} else if v, ok, err := lookupEnvFile(copts, env, envKey(o, derived)); err != nil {
+			if envErr == nil {
+				envErr = err
+			}
+		} else if ok {
+			// the variable itself is not set, so the content of its file becomes the default
+			o.Default = splitEnv(o, v)
+		} else if key, ok := derived[o]; ok {
+			// go-flags only reads the env tags, so the derived variables become defaults like the env files
+			if v, ok := lookupEnv(env, key); ok {
+				o.Default = splitEnv(o, v)
+			}
+		} else if v, ok := env[o.EnvKeyWithNamespace()]; ok && o.EnvDefaultKey != "" {
+			// go-flags reads the env variables in place of the defaults, so the variables of
+			// the env files become defaults that the real env variables still override
+			o.Default = splitEnv(o, v.value)
+		}
+		if !uses(Flags) {
+			o.Required = false
+		}
+	})
+
+	if envErr != nil {
+		return nil, nil, envErr
+	}
+
+	args := copts.args
+	if !uses(Flags) {
+		args = []string{}
 	}
 
-	_, err := p.ParseArgs(copts.args)
+	_, err = p.ParseArgs(args)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse command line args")
+		return nil, nil, errors.Wrap(err, "failed to parse command line args")
 	}
 
 	if copts.configFlagOption != nil {
@@ -137,52 +325,183 @@ func parseFlags(cfg any, defaults bool, copts *confOptions) ([]configPath, error
 				path: path,
 			}
 		}
-		return paths, nil
+		return p, paths, nil
+	}
+	return p, nil, nil
+}
+
+// parseConfigPaths runs the parser over all the inputs at once, which reports parse errors and prints the help message
+func parseConfigPaths(copts *confOptions, t reflect.Type, env map[string]dotenvVar) ([]configPath, error) {
+	_, paths, err := parseFlags(reflect.New(t).Interface(), copts, copts.flagOpts, env, Defaults, Env, Flags)
+	return paths, err
+}
+
+// loadFlags loads only the values of one of the flag parser layers into a new config
+func loadFlags(copts *confOptions, t reflect.Type, env map[string]dotenvVar, l Layer) (*layer, error) {
+	cfg := reflect.New(t)
+	p, _, err := parseFlags(cfg.Interface(), copts, copts.flagOpts&^flags.PrintErrors, env, l)
+	if err != nil {
+		return nil, err
+	}
+	switch l {
+	case Defaults:
+		return newFlagLayer(copts, p, cfg, defaultOrigin), nil
+	case Env:
+		return newFlagLayer(copts, p, cfg, envOrigin(copts, env, derivedEnvKeys(copts, p))), nil
+	}
+	return newFlagLayer(copts, p, cfg, flagOrigin), nil
+}
+
+func newFlagLayer(copts *confOptions, p *flagParser, cfg reflect.Value, origin func(*flags.Option) (Origin, bool)) *layer {
+	l := &layer{value: cfg, set: make(map[string]Origin)}
+	for o, path := range optionFields(p, cfg.Elem().Type()) {
+		if origin, ok := origin(o); ok {
+			l.set[path] = origin
+		}
 	}
-	return nil, nil
+	return l
+}
+
+func defaultOrigin(o *flags.Option) (Origin, bool) {
+	return Origin{Layer: Defaults, Name: o.String()}, len(o.Default) > 0
 }
 
-func mergeDefaults(cfg any, copts *confOptions) ([]configPath, error) {
-	return parseFlags(cfg, true, copts)
+func envOrigin(copts *confOptions, env map[string]dotenvVar, derived map[*flags.Option]string) func(o *flags.Option) (Origin, bool) {
+	return func(o *flags.Option) (Origin, bool) {
+		key := envKey(o, derived)
+		if key == "" {
+			return Origin{}, false
+		}
+		if _, ok := os.LookupEnv(key); ok {
+			return Origin{Layer: Env, Name: key}, true
+		}
+		if v, ok := env[key]; ok {
+			return Origin{Layer: Env, Name: key + " (" + v.path + ")"}, true
+		}
+		if copts.envFileSuffix != "" {
+			if _, ok := lookupEnv(env, key+copts.envFileSuffix); ok {
+				return Origin{Layer: Env, Name: key + copts.envFileSuffix}, true
+			}
+		}
+		return Origin{}, false
+	}
 }
 
-func mergeConfigFiles(copts *confOptions, cfg any, paths ...configPath) (loadedPaths []string, err error) {
-	loadedPaths = make([]string, 0)
+func flagOrigin(o *flags.Option) (Origin, bool) {
+	// go-flags marks options that got a default or env value as set too
+	return Origin{Layer: Flags, Name: o.String()}, o.IsSet() && !o.IsSetDefault()
+}
+
+// stdinPath is the name of the config file that the - path reads from stdin
+const stdinPath = "<stdin>"
+
+// resolvePaths splits the format prefixes off the paths, gives URLs their sources
+// and replaces the - path with stdin, which can be read only once
+func resolvePaths(copts *confOptions, paths []configPath) ([]configPath, error) {
+	stdin := 0
+	for i := range paths {
+		paths[i] = splitFormat(copts, paths[i])
+		if isURL(paths[i].path) && paths[i].src == nil {
+			paths[i].src = urlSource(copts, paths[i].path)
+		}
+		if paths[i].path != "-" || paths[i].src != nil {
+			continue
+		}
+
+		stdin++
+		if stdin > 1 {
+			return nil, errors.New("stdin can only be read once, but - is given as a config file path more than once")
+		}
+		paths[i].path = stdinPath
+		paths[i].src = copts.stdin
+		if paths[i].format == "" {
+			paths[i].format = copts.stdinFormat
+		}
+	}
+	return paths, nil
+}
+
+func loadConfigFiles(copts *confOptions, t reflect.Type, fields []field, env map[string]dotenvVar, paths ...configPath) ([]*layer, error) {
+	layers := make([]*layer, 0, len(paths))
 	for _, path := range paths {
-		ok, err := mergeConfigFile(path.optional, copts, cfg, path.path)
+		l, err := loadConfigFile(copts, t, fields, env, path)
 		if err != nil {
-			return loadedPaths, errors.Wrapf(err, "failed to merge config file %s", path.path)
+			return nil, errors.Wrapf(err, "failed to merge config file %s", path.path)
 		}
-		if ok {
-			loadedPaths = append(loadedPaths, path.path)
+		if l != nil {
+			layers = append(layers, l)
 		}
 	}
-	return loadedPaths, nil
+	return layers, nil
 }
 
-func mergeConfigFile(optional bool, copts *confOptions, cfg any, path string) (ok bool, err error) {
-	f, err := os.Open(path)
+// loadConfigFile decodes a config file into a new config, it returns a nil layer for missing optional files
+func loadConfigFile(copts *confOptions, t reflect.Type, fields []field, env map[string]dotenvVar, path configPath) (*layer, error) {
+	data, err := readConfigFile(copts, path)
 	if err != nil {
-		if optional && stderr.Is(err, fs.ErrNotExist) {
-			return false, nil
+		if path.optional && stderr.Is(err, fs.ErrNotExist) {
+			return nil, nil
 		}
-		return false, errors.Wrapf(err, "failed to open required config file %s", path)
+		return nil, errors.Wrapf(err, "failed to open required config file %s", path.path)
 	}
-	defer f.Close()
 
-	dec, err := getDecoder(copts, path)
+	// the fields with an expand tag are expanded after decoding, unless the whole file was expanded already
+	lookup := func(key string) (string, bool) { return lookupEnv(env, key) }
+	var tagged []field
+	if copts.expandEnv && expandable(copts, path) {
+		data, err = expandEnv(data, lookup)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to expand env variables")
+		}
+	} else {
+		tagged = expandTaggedFields(t, fields)
+	}
+
+	dec, err := decoderFor(copts, t, path, data)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	if err := dec(cfg, f); err != nil && !stderr.Is(err, io.EOF) {
-		return false, err
+
+	cfg, set, err := probeFields(t, fields, func(cfg any) error {
+		if err := dec(cfg, bytes.NewReader(data)); err != nil && !stderr.Is(err, io.EOF) {
+			return err
+		}
+		if err := expandFields(reflect.ValueOf(cfg), tagged, lookup); err != nil {
+			return errors.Wrap(err, "failed to expand env variables")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l := &layer{value: cfg, set: make(map[string]Origin, len(set)), mergeMaps: true}
+	for f := range set {
+		l.set[f] = Origin{Layer: Files, Name: path.path}
+	}
+	return l, nil
+}
+
+// readConfigFile reads a config file from its source, a URL, the file system of the FS option or the OS
+func readConfigFile(copts *confOptions, path configPath) ([]byte, error) {
+	switch {
+	case path.src != nil && path.src.http != nil:
+		return path.src.http.fetch(copts)
+	case path.src != nil && path.src.layout != nil:
+		return path.src.layout.read(copts, path.path)
+	case path.src != nil && path.src.fsys != nil:
+		return fs.ReadFile(path.src.fsys, fsPath(path.path))
+	case path.src != nil:
+		return path.src.read()
+	case copts.fsys != nil:
+		return fs.ReadFile(copts.fsys, fsPath(path.path))
 	}
-	return true, nil
+	return os.ReadFile(path.path)
 }
 
-func mergeWithoutDefaults(cfg any, copts *confOptions) error {
-	_, err := parseFlags(cfg, false, copts)
-	return err
+// fsPath turns a path into the unrooted slash separated form that fs.FS expects
+func fsPath(p string) string {
+	return strings.TrimPrefix(pathpkg.Clean(filepath.ToSlash(p)), "/")
 }
 
 func eachOption(c *flags.Command, f func(*flags.Command, *flags.Group, *flags.Option)) {
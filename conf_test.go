@@ -85,6 +85,40 @@ var flagOverrides = &defaultOptions{
 	SliceDefault: []int{4, 5, 6},
 }
 
+var zeroOverrides = &defaultOptions{
+	Int:            3,
+	IntDefault:     0,
+	Float64Default: 0,
+	StringDefault:  "",
+	TimeDefault:    0,
+	Map:            map[string]int{},
+	MapDefault: map[string]int{
+		"a": 1,
+	},
+	SliceDefault: []int{},
+}
+
+var zeroFlagOverrides = &defaultOptions{
+	Int:            0,
+	IntDefault:     0,
+	Float64:        2.712,
+	Float64Default: 1.1234,
+	String:         "asdf",
+	StringDefault:  "",
+	Time:           13 * time.Second,
+	TimeDefault:    0,
+	Map: map[string]int{
+		"val1": 3,
+		"val2": 4,
+	},
+	MapDefault: map[string]int{
+		"val21": 21,
+		"val22": 22,
+	},
+	Slice:        []int{1, 2, 3},
+	SliceDefault: []int{4, 5, 6},
+}
+
 func Test_Load_ConfigFiles(t *testing.T) {
 	var tcs = map[string]struct {
 		opts     []conf.ConfOption
@@ -190,10 +224,50 @@ func Test_Load_ConfigFiles(t *testing.T) {
 			opts:     []conf.ConfOption{conf.Paths("testdata/config.json"), conf.Args([]string{"--i=4", "--id=5", "--t=17s", "--td=19s"})},
 			expected: flagOverrides,
 		},
+		"no args > paths > JSON5 with default overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config.json5"), conf.Args([]string{})},
+			expected: defaultOverrides,
+		},
+		"no args > paths > JSONC with default overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config.jsonc"), conf.Args([]string{})},
+			expected: defaultOverrides,
+		},
+		"no args > paths > XML with default overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config.xml"), conf.Args([]string{})},
+			expected: defaultOverrides,
+		},
+		"no args > paths > INI with default overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config.ini"), conf.Args([]string{})},
+			expected: defaultOverrides,
+		},
+		"value args > paths > INI with default overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config.ini"), conf.Args([]string{"--i=4", "--id=5", "--t=17s", "--td=19s"})},
+			expected: flagOverrides,
+		},
 		"value args > default flag config path > YAML with non-default overrides > TOML with default overrides": {
 			opts:     []conf.ConfOption{conf.ConfigFlag("conf", "testdata/config.yaml", "testdata/config.toml"), conf.Args([]string{"--i=4", "--id=5", "--t=17s", "--td=19s"})},
 			expected: flagOverrides,
 		},
+		"no args > paths > YAML with zero overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config-zero.yaml"), conf.Args([]string{})},
+			expected: zeroOverrides,
+		},
+		"no args > paths > TOML with zero overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config-zero.toml"), conf.Args([]string{})},
+			expected: zeroOverrides,
+		},
+		"no args > paths > JSON with zero overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config-zero.json"), conf.Args([]string{})},
+			expected: zeroOverrides,
+		},
+		"zero value args > paths > YAML with default overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config.yaml"), conf.Args([]string{"--i=0", "--id=0", "--strd=", "--td=0s"})},
+			expected: zeroFlagOverrides,
+		},
+		"zero value args > paths > TOML with default overrides": {
+			opts:     []conf.ConfOption{conf.Paths("testdata/config.toml"), conf.Args([]string{"--i=0", "--id=0", "--strd=", "--td=0s"})},
+			expected: zeroFlagOverrides,
+		},
 	}
 
 	for name, tc := range tcs {
@@ -0,0 +1,213 @@
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
+)
+
+// consulSource reads the keys under a prefix of the Consul KV store.
+// It keeps the last response and its X-Consul-Index, which a Watcher uses for blocking queries.
+type consulSource struct {
+	address string
+	prefix  string
+
+	mu      sync.Mutex
+	fetched bool
+	data    []byte
+	index   uint64
+}
+
+// consulEntry is an entry of the response of the KV API, Value holds the base64 encoded value or null for folders
+type consulEntry struct {
+	Key   string
+	Value []byte
+}
+
+func newConsulSource(address, prefix string) *consulSource {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	// the prefix ends at a slash, so that myapp does not read the keys of myapp2
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &consulSource{address: strings.TrimSuffix(address, "/"), prefix: prefix}
+}
+
+// url returns the URL of the prefix, it is also the name of the config file in errors and origins
+func (s *consulSource) url() string {
+	return s.address + "/v1/kv/" + s.prefix
+}
+
+// consulOf returns the Consul source of a config path
+func consulOf(path configPath) (*consulSource, bool) {
+	if path.src == nil {
+		return nil, false
+	}
+	s, ok := path.src.layout.(*consulSource)
+	return s, ok
+}
+
+// read reads the keys without blocking
+func (s *consulSource) read(copts *confOptions, _ string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), copts.httpTimeout)
+	defer cancel()
+
+	data, index, err := s.fetch(ctx, copts, 0, 0)
+	s.store(data, index, err)
+	return data, err
+}
+
+// cached returns the last response without a request, so that polling a Watcher does not query Consul
+func (s *consulSource) cached(copts *confOptions) ([]byte, error) {
+	s.mu.Lock()
+	fetched, data := s.fetched, s.data
+	s.mu.Unlock()
+
+	if !fetched {
+		return s.read(copts, "")
+	}
+	if data == nil {
+		return nil, &fs.PathError{Op: "get", Path: s.url(), Err: fs.ErrNotExist}
+	}
+	return data, nil
+}
+
+func (s *consulSource) store(data []byte, index uint64, err error) {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetched = true
+	s.data = data
+	s.index = index
+}
+
+// fetch reads the keys under the prefix, with an index it is a blocking query that returns when the index changes
+// or wait has passed
+func (s *consulSource) fetch(ctx context.Context, copts *confOptions, index uint64, wait time.Duration) ([]byte, uint64, error) {
+	q := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", wait.String())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url()+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if copts.consulToken != "" {
+		req.Header.Set("X-Consul-Token", copts.consulToken)
+	}
+
+	resp, err := copts.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// Consul answers 404 when no key has the prefix, which is skipped like a missing optional file
+		return nil, newIndex, &fs.PathError{Op: "get", Path: s.url(), Err: fs.ErrNotExist}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, 0, errors.Errorf("get %s: %s", s.url(), resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, newIndex, nil
+}
+
+// watch runs blocking queries until ctx is done, keeps the last response in the cache
+// and calls changed when the X-Consul-Index of the keys changes
+func (s *consulSource) watch(ctx context.Context, copts *confOptions, changed func(), fail func(error)) {
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		index := s.index
+		s.mu.Unlock()
+
+		// Consul adds up to wait/16 of jitter to a blocking query
+		reqCtx, cancel := context.WithTimeout(ctx, copts.consulWait+copts.consulWait/16+copts.httpTimeout)
+		data, newIndex, err := s.fetch(reqCtx, copts, index, copts.consulWait)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fail(errors.Wrapf(err, "failed to watch %s", s.url()))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(copts.pollInterval):
+			}
+			continue
+		}
+
+		// an index that goes backwards or is not set starts over with a non blocking query
+		if newIndex < index {
+			newIndex = 0
+		}
+		s.store(data, newIndex, err)
+		if newIndex != index {
+			changed()
+		}
+		if newIndex == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(copts.pollInterval):
+			}
+		}
+	}
+}
+
+func (s *consulSource) decoder(copts *confOptions) DecoderFunc {
+	return consulDecoder(copts, s.prefix)
+}
+
+// consulDecoder decodes a response of the KV API. The keys below the prefix are split at their slashes
+// and matched to the options of cfg like the keys of a .properties file,
+// so server/tls/cert sets the --server-tls-cert flag when the delimiter is "-".
+func consulDecoder(copts *confOptions, prefix string) DecoderFunc {
+	return func(cfg any, r io.Reader) error {
+		var entries []consulEntry
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return errors.Wrap(err, "failed to decode the Consul KV response")
+		}
+
+		var props []property
+		for _, e := range entries {
+			key := strings.TrimPrefix(e.Key, prefix)
+			if key == "" || strings.HasSuffix(key, "/") || e.Value == nil {
+				continue
+			}
+			props = append(props, property{key: key, value: string(e.Value)})
+		}
+		if len(props) == 0 {
+			return io.EOF
+		}
+		p, err := newFlagParser(copts, cfg, flags.None)
+		if err != nil {
+			return err
+		}
+		return setNamespacedOptions(p, props, "/", copts.strict)
+	}
+}
@@ -0,0 +1,192 @@
+package conf_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/require"
+)
+
+// consulServer speaks the recursive reads of the Consul KV API, including blocking queries
+type consulServer struct {
+	mu       sync.Mutex
+	keys     map[string]string
+	index    uint64
+	changed  chan struct{}
+	blocking int
+	headers  http.Header
+}
+
+func newConsulServer(t *testing.T) (*consulServer, *httptest.Server) {
+	s := &consulServer{keys: map[string]string{}, index: 1, changed: make(chan struct{})}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func (s *consulServer) set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = value
+	s.index++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *consulServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	if r.URL.Query().Get("recurse") != "true" {
+		http.Error(w, "expected a recursive read", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.headers = r.Header.Clone()
+	if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index > 0 && index >= s.index {
+		s.blocking++
+		changed := s.changed
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+		}
+		s.mu.Lock()
+	}
+	defer s.mu.Unlock()
+
+	type entry struct {
+		Key         string
+		Value       []byte
+		ModifyIndex uint64
+	}
+	var entries []entry
+	for key, value := range s.keys {
+		if strings.HasPrefix(key, prefix) {
+			e := entry{Key: key, ModifyIndex: s.index}
+			if !strings.HasSuffix(key, "/") {
+				e.Value = []byte(value)
+			}
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	if len(entries) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+func Test_Load_Consul(t *testing.T) {
+	s, ts := newConsulServer(t)
+	s.set("myapp/", "")
+	s.set("myapp/name", "consul")
+	s.set("myapp/server/", "")
+	s.set("myapp/server/tls/cert", "/etc/tls/cert.pem")
+	s.set("myapp/server/host", "h1")
+	s.set("myapp/server/tag", "env:prod")
+	s.set("other/name", "other")
+
+	for _, delimiter := range []string{"-", "."} {
+		t.Run(delimiter, func(t *testing.T) {
+			cfg, err := conf.Load[propertiesOptions](
+				conf.Consul(ts.URL, "myapp/"),
+				conf.ConsulToken("secret"),
+				conf.HTTPHeader("Authorization", "Bearer token"),
+				conf.Delimiter(delimiter),
+				conf.Args([]string{"--name=flag"}),
+				conf.WithFlagOpts(flags.None),
+				conf.Strict(),
+			)
+			require.NoError(t, err)
+			require.Equal(t, &propertiesOptions{
+				Name: "flag",
+				Server: propertiesServerOptions{
+					TLS:   propertiesTLSOptions{Cert: "/etc/tls/cert.pem"},
+					Hosts: []string{"h1"},
+					Tags:  map[string]string{"env": "prod"},
+				},
+			}, cfg)
+			require.Equal(t, "secret", s.headers.Get("X-Consul-Token"))
+			require.Empty(t, s.headers.Get("Authorization"))
+		})
+	}
+}
+
+func Test_Load_Consul_Prefix(t *testing.T) {
+	s, ts := newConsulServer(t)
+	s.set("myapp/name", "consul")
+	s.set("myapp2/name", "other")
+
+	cfg, err := conf.Load[propertiesOptions](conf.Consul(ts.URL, "/myapp"), conf.Args([]string{}), conf.Strict())
+	require.NoError(t, err)
+	require.Equal(t, "consul", cfg.Name)
+}
+
+func Test_Load_Consul_Errors(t *testing.T) {
+	s, ts := newConsulServer(t)
+	s.set("myapp/server/tls/crt", "x")
+
+	_, err := conf.Load[propertiesOptions](conf.Consul(ts.URL, "myapp"), conf.Args([]string{}), conf.Strict())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown keys: server/tls/crt")
+
+	_, err = conf.Load[propertiesOptions](conf.Consul(ts.URL, "missing/"), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open required config file "+ts.URL+"/v1/kv/missing/")
+
+	cfg, err := conf.Load[propertiesOptions](conf.OptionalConsul(ts.URL, "missing/"), conf.Args([]string{"--name=a"}))
+	require.NoError(t, err)
+	require.Equal(t, "a", cfg.Name)
+}
+
+func Test_Watch_Consul(t *testing.T) {
+	s, ts := newConsulServer(t)
+	s.set("myapp/name", "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := conf.Watch[propertiesOptions](ctx,
+		conf.Consul(ts.URL, "myapp/"),
+		conf.Args([]string{}),
+		conf.PollInterval(time.Hour),
+		conf.ConsulWait(time.Minute),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "a", w.Config().Name)
+
+	updates := make(chan *propertiesOptions, 1)
+	w.Subscribe(func(cfg *propertiesOptions) {
+		updates <- cfg
+	})
+
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.blocking >= 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.set("myapp/name", "b")
+	select {
+	case cfg := <-updates:
+		require.Equal(t, "b", cfg.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
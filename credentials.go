@@ -0,0 +1,156 @@
+package conf
+
+import (
+	"encoding/json"
+	stderr "errors"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// credentialsPath is the name of the credentials directory in errors and origins when systemd did not set it
+const credentialsPath = "$CREDENTIALS_DIRECTORY"
+
+// credentialsSource is the layout of the systemd credentials directory of Credentials
+type credentialsSource struct{}
+
+func (credentialsSource) read(_ *confOptions, path string) ([]byte, error) {
+	return readCredentials(path)
+}
+
+func (credentialsSource) decoder(copts *confOptions) DecoderFunc {
+	return credentialsDecoder(copts)
+}
+
+// readCredentials reads the credentials directory like Dir does, a unit without credentials has none
+// so that the required credentials are still reported
+func readCredentials(path string) ([]byte, error) {
+	if path == credentialsPath {
+		return []byte("{}"), nil
+	}
+	data, err := readDir(path)
+	if stderr.Is(err, fs.ErrNotExist) {
+		return []byte("{}"), nil
+	}
+	return data, err
+}
+
+// credentialsDecoder decodes what readCredentials read. The fields with a credential tag read the credential
+// that the tag names, or that is named after their yaml keys, and the other credentials are matched to the fields
+// like the files of Dir. The errors name the credentials but never contain their values.
+func credentialsDecoder(copts *confOptions) DecoderFunc {
+	return func(cfg any, r io.Reader) error {
+		var tree dirTree
+		if err := json.NewDecoder(r).Decode(&tree); err != nil {
+			return errors.Wrap(err, "failed to decode credentials")
+		}
+
+		v := reflect.ValueOf(cfg)
+		t := v.Elem().Type()
+		type tagged struct {
+			field
+			name string
+			data []byte
+		}
+		var fields []tagged
+		var missing []string
+		for _, f := range fieldsOf(t) {
+			name, optional, ok := credentialTag(t, f)
+			if !ok {
+				continue
+			}
+			data, ok := tree.Files[name]
+			if !ok {
+				if !optional {
+					missing = append(missing, name)
+				}
+				continue
+			}
+			// the credential is read only by the tagged field
+			delete(tree.Files, name)
+			fields = append(fields, tagged{field: f, name: name, data: data})
+		}
+		if len(missing) > 0 {
+			return errors.Errorf("missing required credentials: %s, the unit needs a LoadCredential= or SetCredential= for each",
+				strings.Join(missing, ", "))
+		}
+
+		if len(tree.Files) > 0 || len(tree.Dirs) > 0 {
+			n := tree.node()
+			tagStrings(n, reflect.TypeOf(cfg))
+			if copts.strict {
+				var keys []string
+				unknownYAMLKeys(n, reflect.TypeOf(cfg), "", &keys)
+				if len(keys) > 0 {
+					return errors.Wrap(unknownKeysError(keys), "failed to decode credentials")
+				}
+			}
+			if err := n.Decode(cfg); err != nil {
+				// the errors of yaml.v3 quote the values
+				return errors.New("failed to decode credentials: a credential does not fit the type of its field")
+			}
+		} else if len(fields) == 0 {
+			return io.EOF
+		}
+
+		for _, f := range fields {
+			x := reflect.New(f.typ)
+			n := &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(string(f.data))}
+			tagStrings(n, f.typ)
+			if err := n.Decode(x.Interface()); err != nil {
+				return errors.Errorf("failed to decode credential %s: it is not a valid %s for field %s", f.name, f.typ, f.path)
+			}
+			f.set(v, x.Elem())
+		}
+		return nil
+	}
+}
+
+// credentialTag returns the credential of a field with a credential:"name" or credential:"name,optional" tag,
+// a tag without a name uses the yaml keys of the field joined with dots
+func credentialTag(t reflect.Type, f field) (name string, optional bool, ok bool) {
+	tag, ok := t.FieldByIndex(f.index).Tag.Lookup("credential")
+	if !ok || tag == "-" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	for _, flag := range parts[1:] {
+		switch flag {
+		case "optional":
+			optional = true
+		case "required":
+			optional = false
+		}
+	}
+	name = parts[0]
+	if name == "" {
+		name = yamlKeyPath(t, f.index)
+	}
+	return name, optional, true
+}
+
+// yamlKeyPath returns the yaml keys of the field at index, joined with dots
+func yamlKeyPath(t reflect.Type, index []int) string {
+	var path string
+	for _, i := range index {
+		t = indirectType(t)
+		sf := t.Field(i)
+		if key, inline, _ := yamlFieldName(sf); !inline {
+			path = keyPath(path, key)
+		}
+		t = sf.Type
+	}
+	return path
+}
+
+func credentialsDir() string {
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		return dir
+	}
+	return credentialsPath
+}
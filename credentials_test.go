@@ -0,0 +1,126 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type credentialsDBOptions struct {
+	Password string `yaml:"password" credential:"db_password"`
+	Port     int    `yaml:"port" credential:",optional"`
+}
+
+type credentialsOptions struct {
+	DB      credentialsDBOptions `yaml:"db"`
+	APIKey  string               `yaml:"apiKey" credential:"api_key,optional" default:"none" long:"api-key"`
+	Token   string               `yaml:"token"`
+	Ignored string               `yaml:"ignored" credential:"-"`
+}
+
+func writeCredentials(t *testing.T, creds map[string]string) string {
+	dir := t.TempDir()
+	for name, value := range creds {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(value), 0o400))
+	}
+	return dir
+}
+
+func Test_Load_Credentials(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+
+	var tcs = []struct {
+		msg      string
+		creds    map[string]string
+		expected credentialsOptions
+	}{
+		{
+			msg:   "required credentials",
+			creds: map[string]string{"db_password": "s3cret\n"},
+			expected: credentialsOptions{
+				DB:     credentialsDBOptions{Password: "s3cret"},
+				APIKey: "none",
+			},
+		},
+		{
+			msg: "optional and key-per-file credentials",
+			creds: map[string]string{
+				"db_password": "s3cret",
+				"db.port":     "5432",
+				"api_key":     "k3y",
+				"token":       "t0ken",
+			},
+			expected: credentialsOptions{
+				DB:     credentialsDBOptions{Password: "s3cret", Port: 5432},
+				APIKey: "k3y",
+				Token:  "t0ken",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.msg, func(t *testing.T) {
+			os.Setenv("CREDENTIALS_DIRECTORY", writeCredentials(t, tc.creds))
+
+			cfg, err := conf.Load[credentialsOptions](conf.Credentials(), conf.Args([]string{}))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, *cfg)
+		})
+	}
+}
+
+func Test_Load_Credentials_NullStrings(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("CREDENTIALS_DIRECTORY", writeCredentials(t, map[string]string{"db_password": "~", "token": "null"}))
+
+	cfg, err := conf.Load[credentialsOptions](conf.Credentials(), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, credentialsOptions{DB: credentialsDBOptions{Password: "~"}, APIKey: "none", Token: "null"}, *cfg)
+}
+
+func Test_Load_Credentials_Report(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	dir := writeCredentials(t, map[string]string{"db_password": "s3cret", "api_key": "k3y"})
+	os.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	cfg, report, err := conf.LoadWithReport[credentialsOptions](conf.Credentials(), conf.Args([]string{"--api-key=flag"}))
+	require.NoError(t, err)
+	require.Equal(t, "flag", cfg.APIKey)
+
+	field, ok := report.Field("DB.Password")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Files, Name: dir}, field.Origin)
+}
+
+func Test_Load_Credentials_Errors(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+
+	os.Unsetenv("CREDENTIALS_DIRECTORY")
+	_, err := conf.Load[credentialsOptions](conf.Credentials(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to merge config file $CREDENTIALS_DIRECTORY: missing required credentials: db_password")
+
+	dir := writeCredentials(t, map[string]string{"api_key": "k3y"})
+	os.Setenv("CREDENTIALS_DIRECTORY", dir)
+	_, err = conf.Load[credentialsOptions](conf.Credentials(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to merge config file "+dir+": missing required credentials: db_password, the unit needs a LoadCredential= or SetCredential= for each")
+
+	os.Setenv("CREDENTIALS_DIRECTORY", writeCredentials(t, map[string]string{"db_password": "s3cret", "db.port": "p0rt"}))
+	_, err = conf.Load[credentialsOptions](conf.Credentials(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to decode credential db.port: it is not a valid int for field DB.Port")
+	require.NotContains(t, err.Error(), "p0rt")
+
+	os.Setenv("CREDENTIALS_DIRECTORY", writeCredentials(t, map[string]string{"db_password": "s3cret", "unknown": "x"}))
+	_, err = conf.Load[credentialsOptions](conf.Credentials(), conf.Strict(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to decode credentials: unknown keys: unknown")
+}
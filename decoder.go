@@ -1,11 +1,15 @@
 package conf
 
 import (
+	"fmt"
 	"io"
 	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,29 +17,365 @@ type DecoderFunc func(cfg any, r io.Reader) error
 
 func getDecoder(copts *confOptions, path string) (DecoderFunc, error) {
 	ext := filepath.Ext(path)
-	dec, ok := copts.decoders[ext]
+	dec, ok := decoderByExt(copts, ext)
 	if !ok {
 		return nil, errors.Errorf("no decoder for %s", ext)
 	}
 	return dec, nil
 }
 
+func decoderByExt(copts *confOptions, ext string) (DecoderFunc, bool) {
+	dec, ok := copts.decoders[ext]
+	if !ok {
+		dec, ok = DefaultDecoders[ext]
+	}
+	if !ok {
+		return nil, false
+	}
+	opts := decodeOptions{strict: copts.strict, legacyJSON: copts.legacyJSON, delimiter: copts.delimiter}
+	return func(cfg any, r io.Reader) error {
+		return dec(cfg, &optionsReader{Reader: r, opts: opts})
+	}, true
+}
+
+// decodeOptions are the options of Load that the built-in decoders follow: Strict makes them strict, LegacyJSON
+// turns JSONDecoder into LegacyJSONDecoder and PropertiesDecoder uses the Delimiter
+type decodeOptions struct {
+	strict     bool
+	legacyJSON bool
+	delimiter  string
+}
+
+// optionsReader passes the options of Load to the built-in decoders. Load wraps the content of every config file
+// in it, so the built-in decoders follow the options whether DefaultDecoders or AddDecoder registered them,
+// and also when a decoder of its own calls them with the reader it got.
+type optionsReader struct {
+	io.Reader
+	opts decodeOptions
+}
+
+// optionsOf returns the options that r carries, or the defaults of a built-in decoder that is called directly
+func optionsOf(r io.Reader) decodeOptions {
+	if o, ok := r.(*optionsReader); ok {
+		return o.opts
+	}
+	return decodeOptions{delimiter: "-"}
+}
+
 var DefaultDecoders = map[string]DecoderFunc{
 	".yaml": YAMLDecoder,
 	".yml":  YAMLDecoder,
 	".json": JSONDecoder,
 	".toml": TOMLDecoder,
+	".env":  DotenvDecoder,
+	".ini":  INIDecoder,
+	".cfg":  INIDecoder,
+
+	".properties": PropertiesDecoder,
+	".json5":      JSON5Decoder,
+	".jsonc":      JSONCDecoder,
+	".xml":        XMLDecoder,
 }
 
-var YAMLDecoder = func(cfg any, r io.Reader) error {
+var YAMLDecoder DecoderFunc = decodeYAML
+
+// JSONDecoder uses the json tags, with the LegacyJSON option it decodes like LegacyJSONDecoder
+var JSONDecoder DecoderFunc = func(cfg any, r io.Reader) error {
+	opts := optionsOf(r)
+	if opts.legacyJSON {
+		return decodeLegacyJSON(cfg, r)
+	}
+	return errors.Wrap(decodeJSON(cfg, r, opts.strict), "failed to decode json")
+}
+
+var TOMLDecoder DecoderFunc = decodeTOML
+
+// DotenvDecoder sets the options whose env keys are defined in the file
+var DotenvDecoder DecoderFunc = func(cfg any, r io.Reader) error {
+	return errors.Wrap(decodeDotenv(cfg, r, optionsOf(r).strict), "failed to decode dotenv")
+}
+
+// INIDecoder sets the options of the config from [section] and key = value lines, see decodeINI for the naming
+var INIDecoder DecoderFunc = func(cfg any, r io.Reader) error {
+	return errors.Wrap(decodeINI(cfg, r, optionsOf(r).strict), "failed to decode ini")
+}
+
+// PropertiesDecoder separates namespaces with the default "-" delimiter,
+// the .properties files of Load use the Delimiter option instead
+var PropertiesDecoder DecoderFunc = func(cfg any, r io.Reader) error {
+	opts := optionsOf(r)
+	return errors.Wrap(decodeProperties(cfg, r, opts.delimiter, opts.strict), "failed to decode properties")
+}
+
+// JSON5Decoder and JSONCDecoder accept comments, trailing commas, unquoted keys and single quoted strings,
+// and map the fields like JSONDecoder
+var JSON5Decoder DecoderFunc = func(cfg any, r io.Reader) error {
+	return errors.Wrap(decodeJSON5(cfg, r, optionsOf(r).strict), "failed to decode json5")
+}
+
+var JSONCDecoder DecoderFunc = func(cfg any, r io.Reader) error {
+	return errors.Wrap(decodeJSON5(cfg, r, optionsOf(r).strict), "failed to decode jsonc")
+}
+
+// XMLDecoder matches elements to fields by their xml tags, or by the keys that YAMLDecoder uses
+var XMLDecoder DecoderFunc = func(cfg any, r io.Reader) error {
+	return errors.Wrap(decodeXML(cfg, r, optionsOf(r).strict), "failed to decode xml")
+}
+
+// LegacyJSONDecoder reads JSON files with yaml.v3, it uses the yaml tags and accepts YAML syntax
+var LegacyJSONDecoder DecoderFunc = decodeLegacyJSON
+
+func decodeYAML(cfg any, r io.Reader) error {
+	if optionsOf(r).strict {
+		return errors.Wrap(decodeStrictYAML(cfg, r), "failed to decode yaml")
+	}
 	err := yaml.NewDecoder(r).Decode(cfg)
 	return errors.Wrap(err, "failed to decode yaml")
 }
-var JSONDecoder = func(cfg any, r io.Reader) error {
+
+func decodeLegacyJSON(cfg any, r io.Reader) error {
+	if optionsOf(r).strict {
+		return errors.Wrap(decodeStrictYAML(cfg, r), "failed to decode json")
+	}
 	err := yaml.NewDecoder(r).Decode(cfg)
 	return errors.Wrap(err, "failed to decode json")
 }
-var TOMLDecoder = func(cfg any, r io.Reader) error {
-	_, err := toml.DecodeReader(r, cfg)
-	return errors.Wrap(err, "failed to decode toml")
+
+func decodeTOML(cfg any, r io.Reader) error {
+	md, err := toml.DecodeReader(r, cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode toml")
+	}
+
+	undecoded := md.Undecoded()
+	if !optionsOf(r).strict || len(undecoded) == 0 {
+		return nil
+	}
+	keys := make([]string, len(undecoded))
+	for i, key := range undecoded {
+		keys[i] = key.String()
+	}
+	return errors.Wrap(unknownKeysError(keys), "failed to decode toml")
+}
+
+// unknownKeysErr is the error of the strict decoders, DetectFormat counts its keys
+type unknownKeysErr struct {
+	keys []string
+}
+
+func (e *unknownKeysErr) Error() string {
+	return "unknown keys: " + strings.Join(e.keys, ", ")
+}
+
+func unknownKeysError(keys []string) error {
+	return errors.WithStack(&unknownKeysErr{keys: keys})
+}
+
+func decodeStrictYAML(cfg any, r io.Reader) error {
+	var n yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&n); err != nil {
+		return err
+	}
+
+	var keys []string
+	unknownYAMLKeys(&n, reflect.TypeOf(cfg), "", &keys)
+	if len(keys) > 0 {
+		return unknownKeysError(keys)
+	}
+	return n.Decode(cfg)
+}
+
+// unknownYAMLKeys collects the paths of the mapping keys in n that yaml.v3 would not decode into a value of type t
+func unknownYAMLKeys(n *yaml.Node, t reflect.Type, path string, keys *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			unknownYAMLKeys(c, t, path, keys)
+		}
+		return
+	case yaml.AliasNode:
+		unknownYAMLKeys(n.Alias, t, path, keys)
+		return
+	}
+
+	pt := reflect.PtrTo(t)
+	if pt.Implements(yamlUnmarshalerType) || pt.Implements(textUnmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields, inlineMap := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Value == "<<" {
+				unknownYAMLKeys(v, t, path, keys)
+				continue
+			}
+			if ft, ok := fields[k.Value]; ok {
+				unknownYAMLKeys(v, ft, keyPath(path, k.Value), keys)
+			} else if inlineMap != nil {
+				unknownYAMLKeys(v, inlineMap.Elem(), keyPath(path, k.Value), keys)
+			} else if k.Line == 0 {
+				// nodes that were not parsed from a file have no lines
+				*keys = append(*keys, keyPath(path, k.Value))
+			} else {
+				*keys = append(*keys, fmt.Sprintf("%s (line %d)", keyPath(path, k.Value), k.Line))
+			}
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			unknownYAMLKeys(n.Content[i+1], t.Elem(), keyPath(path, n.Content[i].Value), keys)
+		}
+	case reflect.Slice, reflect.Array:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, c := range n.Content {
+			unknownYAMLKeys(c, t.Elem(), fmt.Sprintf("%s[%d]", path, i), keys)
+		}
+	}
+}
+
+// yamlFields returns the types of the fields of a struct by the keys yaml.v3 decodes them from,
+// following inlined structs, and the type of an inlined map if there is one
+func yamlFields(t reflect.Type) (map[string]reflect.Type, reflect.Type) {
+	fields := make(map[string]reflect.Type)
+	var inlineMap reflect.Type
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		name, inline, skip := yamlFieldName(sf)
+		if skip {
+			continue
+		}
+		if inline {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			switch ft.Kind() {
+			case reflect.Struct:
+				inner, innerMap := yamlFields(ft)
+				for k, v := range inner {
+					fields[k] = v
+				}
+				if innerMap != nil {
+					inlineMap = innerMap
+				}
+			case reflect.Map:
+				inlineMap = ft
+			}
+			continue
+		}
+		fields[name] = sf.Type
+	}
+	return fields, inlineMap
+}
+
+// yamlFieldPath returns the Go path and the type of the value that yaml.v3 decodes the keys into,
+// keys of maps are shown in brackets
+func yamlFieldPath(t reflect.Type, keys []string) (string, reflect.Type) {
+	var path string
+	for _, key := range keys {
+		t = indirectType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			names, ft, ok := yamlField(t, key)
+			if !ok {
+				return path, t
+			}
+			for _, name := range names {
+				path = joinPath(path, name)
+			}
+			t = ft
+		case reflect.Map:
+			path += "[" + key + "]"
+			t = t.Elem()
+		default:
+			return path, t
+		}
+	}
+	return path, t
+}
+
+// yamlField returns the names of the field that yaml.v3 decodes key into and of the inlined structs around it
+func yamlField(t reflect.Type, key string) ([]string, reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		name, inline, skip := yamlFieldName(sf)
+		switch {
+		case skip:
+		case inline:
+			if ft := indirectType(sf.Type); ft.Kind() == reflect.Struct {
+				if names, ft, ok := yamlField(ft, key); ok {
+					return append([]string{sf.Name}, names...), ft, true
+				}
+			}
+		case name == key:
+			return []string{sf.Name}, sf.Type, true
+		}
+	}
+	return nil, nil, false
+}
+
+// yamlFieldName returns the key that yaml.v3 uses for a struct field
+func yamlFieldName(sf reflect.StructField) (name string, inline bool, skip bool) {
+	tag := sf.Tag.Get("yaml")
+	if tag == "" && !strings.Contains(string(sf.Tag), ":") {
+		tag = string(sf.Tag)
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			return "", true, false
+		}
+	}
+	if sf.PkgPath != "" {
+		return "", false, true
+	}
+	if parts[0] != "" {
+		return parts[0], false, false
+	}
+	return strings.ToLower(sf.Name), false, false
+}
+
+func keyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// setOptions parses no args with the given values as the defaults of the options, so the options are set the same way
+// as from the command line, and the options without values keep what cfg held before
+func setOptions(p *flagParser, values map[*flags.Option][]string) error {
+	eachOption(p.Command, func(c *flags.Command, g *flags.Group, o *flags.Option) {
+		o.EnvDefaultKey = ""
+		o.Required = false
+		o.Default = values[o]
+	})
+
+	_, err := p.ParseArgs([]string{})
+	return err
 }
@@ -0,0 +1,222 @@
+package conf
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// dirTree is the content of a key-per-file directory, a Watcher hashes its JSON form to notice changes
+type dirTree struct {
+	Files map[string][]byte   `json:"files,omitempty"`
+	Dirs  map[string]*dirTree `json:"dirs,omitempty"`
+}
+
+// readDir reads a directory where every file name is a key and its content the value, and every directory a nested
+// struct or map. Names that start with a dot are skipped, which leaves out the ..data and ..<timestamp> entries
+// of Kubernetes volumes while their key symlinks are followed, so a read sees either the old or the new version.
+func readDir(path string) ([]byte, error) {
+	tree, err := readDirTree(path, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+func readDirTree(path string, seen map[string]bool) (*dirTree, error) {
+	// a symlink to a directory around it would make the tree endless
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	if seen[real] {
+		return nil, nil
+	}
+	seen[real] = true
+	defer delete(seen, real)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := &dirTree{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		p := filepath.Join(path, e.Name())
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case info.IsDir():
+			sub, err := readDirTree(p, seen)
+			if err != nil {
+				return nil, err
+			}
+			if sub == nil {
+				continue
+			}
+			if tree.Dirs == nil {
+				tree.Dirs = make(map[string]*dirTree)
+			}
+			tree.Dirs[e.Name()] = sub
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			if tree.Files == nil {
+				tree.Files = make(map[string][]byte)
+			}
+			tree.Files[e.Name()] = data
+		}
+	}
+	return tree, nil
+}
+
+// dirSource is the layout of the key-per-file directories of Dir
+type dirSource struct{}
+
+func (dirSource) read(_ *confOptions, path string) ([]byte, error) {
+	return readDir(path)
+}
+
+func (dirSource) decoder(copts *confOptions) DecoderFunc {
+	return dirDecoder(copts)
+}
+
+// dirDecoder decodes what readDir read. The keys are matched to the fields like the keys of a YAML file,
+// and the values, without the white space around them, are read like unquoted YAML scalars that are strings
+// for string fields.
+func dirDecoder(copts *confOptions) DecoderFunc {
+	return func(cfg any, r io.Reader) error {
+		var tree dirTree
+		if err := json.NewDecoder(r).Decode(&tree); err != nil {
+			return errors.Wrap(err, "failed to decode directory")
+		}
+		if len(tree.Files) == 0 && len(tree.Dirs) == 0 {
+			return io.EOF
+		}
+
+		n := tree.node()
+		tagStrings(n, reflect.TypeOf(cfg))
+		if copts.strict {
+			var keys []string
+			unknownYAMLKeys(n, reflect.TypeOf(cfg), "", &keys)
+			if len(keys) > 0 {
+				return errors.Wrap(unknownKeysError(keys), "failed to decode directory")
+			}
+		}
+		if err := n.Decode(cfg); err != nil {
+			// the errors of yaml.v3 quote the values, which may be secrets
+			if key, path, typ, ok := tree.misfit(reflect.TypeOf(cfg)); ok {
+				return errors.Errorf("failed to decode directory: file %s is not a valid %s for field %s", key, typ, path)
+			}
+			return errors.New("failed to decode directory: a file does not fit the type of its field")
+		}
+		return nil
+	}
+}
+
+// misfit returns the first file, by its slash separated path in the tree, whose value cannot be decoded into its field,
+// and the path and type of the field
+func (t *dirTree) misfit(typ reflect.Type) (key, path string, ft reflect.Type, ok bool) {
+	for _, leaf := range t.leaves("") {
+		keys := strings.Split(leaf, "/")
+		single := &dirTree{}
+		sub := single
+		for _, k := range keys[:len(keys)-1] {
+			sub.Dirs = map[string]*dirTree{k: {}}
+			sub = sub.Dirs[k]
+		}
+		src := t
+		for _, k := range keys[:len(keys)-1] {
+			src = src.Dirs[k]
+		}
+		sub.Files = map[string][]byte{keys[len(keys)-1]: src.Files[keys[len(keys)-1]]}
+
+		n := single.node()
+		tagStrings(n, typ)
+		if err := n.Decode(reflect.New(typ.Elem()).Interface()); err != nil {
+			path, ft := yamlFieldPath(typ, keys)
+			return leaf, path, ft, true
+		}
+	}
+	return "", "", nil, false
+}
+
+// leaves returns the slash separated paths of the files in the tree, sorted
+func (t *dirTree) leaves(prefix string) []string {
+	var leaves []string
+	for name := range t.Files {
+		leaves = append(leaves, prefix+name)
+	}
+	for name, sub := range t.Dirs {
+		leaves = append(leaves, sub.leaves(prefix+name+"/")...)
+	}
+	sort.Strings(leaves)
+	return leaves
+}
+
+// tagStrings tags the scalars that are decoded into strings as !!str, so that files holding ~ or null set the string
+// instead of leaving the field empty
+func tagStrings(n *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if t.Kind() == reflect.String {
+			n.Tag = "!!str"
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields, inlineMap := yamlFields(t)
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if ft, ok := fields[n.Content[i].Value]; ok {
+					tagStrings(n.Content[i+1], ft)
+				} else if inlineMap != nil {
+					tagStrings(n.Content[i+1], inlineMap.Elem())
+				}
+			}
+		case reflect.Map:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				tagStrings(n.Content[i+1], t.Elem())
+			}
+		}
+	}
+}
+
+// node returns the tree as a YAML mapping with sorted keys
+func (t *dirTree) node() *yaml.Node {
+	values := make(map[string]*yaml.Node, len(t.Files)+len(t.Dirs))
+	for name, data := range t.Files {
+		values[name] = &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(string(data))}
+	}
+	for name, sub := range t.Dirs {
+		values[name] = sub.node()
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range names {
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, values[name])
+	}
+	return n
+}
@@ -0,0 +1,150 @@
+package conf_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type dirTLSOptions struct {
+	Cert string `yaml:"cert"`
+}
+
+type dirOptions struct {
+	Name    string            `yaml:"name"`
+	Port    int               `long:"port" default:"80" yaml:"port"`
+	Debug   bool              `yaml:"debug"`
+	Timeout time.Duration     `yaml:"timeout"`
+	TLS     dirTLSOptions     `yaml:"tls"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+// writeDirVersion writes the files of a Kubernetes volume version and points the ..data symlink at it atomically,
+// the keys are symlinks into ..data
+func writeDirVersion(t *testing.T, dir, version string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, version, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+		top, _, _ := strings.Cut(name, "/")
+		key := filepath.Join(dir, top)
+		if _, err := os.Lstat(key); os.IsNotExist(err) {
+			require.NoError(t, os.Symlink(filepath.Join("..data", top), key))
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(version, tmp))
+	require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+}
+
+func Test_Load_Dir(t *testing.T) {
+	dir := t.TempDir()
+	writeDirVersion(t, dir, "..2024_01_01", map[string]string{
+		"name":        "api\n",
+		"debug":       "true",
+		"timeout":     "5s",
+		"tls/cert":    "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+		"labels/env":  "prod",
+		"labels/team": "core",
+		"unknown":     "x",
+		"tls/.hidden": "x",
+	})
+
+	cfg, report, err := conf.LoadWithReport[dirOptions](conf.Dir(dir), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, dirOptions{
+		Name:    "api",
+		Port:    80,
+		Debug:   true,
+		Timeout: 5 * time.Second,
+		TLS:     dirTLSOptions{Cert: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"},
+		Labels:  map[string]string{"env": "prod", "team": "core"},
+	}, *cfg)
+
+	field, ok := report.Field("TLS.Cert")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Files, Name: dir}, field.Origin)
+
+	_, err = conf.Load[dirOptions](conf.Dir(dir), conf.Strict(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to merge config file "+dir+": failed to decode directory: unknown keys: unknown")
+}
+
+func Test_Load_Dir_NullStrings(t *testing.T) {
+	type options struct {
+		User     string            `yaml:"user"`
+		Password *string           `yaml:"password"`
+		Labels   map[string]string `yaml:"labels"`
+		Port     int               `yaml:"port"`
+	}
+	dir := t.TempDir()
+	writeDirVersion(t, dir, "..2024_01_01", map[string]string{
+		"user":         "null",
+		"password":     "~\n",
+		"labels/owner": "Null",
+		"port":         "~",
+	})
+
+	cfg, err := conf.Load[options](conf.Dir(dir), conf.Args([]string{}))
+	require.NoError(t, err)
+	password := "~"
+	require.Equal(t, options{User: "null", Password: &password, Labels: map[string]string{"owner": "Null"}}, *cfg)
+}
+
+func Test_Load_Dir_Missing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	cfg, err := conf.Load[dirOptions](conf.OptionalDir(dir), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, 80, cfg.Port)
+
+	_, err = conf.Load[dirOptions](conf.Dir(dir), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open required config file "+dir)
+}
+
+func Test_Load_Dir_Errors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "name"), []byte("api"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "port"), []byte("eighty"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "labels"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "labels", "env"), []byte("prod"), 0o644))
+
+	_, err := conf.Load[dirOptions](conf.Dir(dir), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to decode directory: file port is not a valid int for field Port")
+	require.NotContains(t, err.Error(), "eighty")
+}
+
+func Test_Watch_Dir(t *testing.T) {
+	dir := t.TempDir()
+	writeDirVersion(t, dir, "..2024_01_01", map[string]string{"name": "v1", "tls/cert": "c1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := conf.Watch[dirOptions](ctx, conf.Dir(dir), conf.Args([]string{}), conf.PollInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, "v1", w.Config().Name)
+
+	updates := make(chan *dirOptions, 1)
+	w.Subscribe(func(cfg *dirOptions) {
+		updates <- cfg
+	})
+
+	writeDirVersion(t, dir, "..2024_01_02", map[string]string{"name": "v2", "tls/cert": "c2"})
+	select {
+	case cfg := <-updates:
+		require.Equal(t, "v2", cfg.Name)
+		require.Equal(t, "c2", cfg.TLS.Cert)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
@@ -0,0 +1,204 @@
+package conf
+
+import (
+	"bytes"
+	stderr "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
+)
+
+// dotenvVar is a variable of a dotenv file and the line it was defined on
+type dotenvVar struct {
+	value string
+	line  int
+	path  string
+}
+
+// parseDotenv reads KEY=VALUE lines with optional export prefixes, # comments and single or double quoted values.
+// Single quoted values are literal, double quoted values support the \n, \r, \t, \", \\ and \$ escapes,
+// and both can span several lines.
+func parseDotenv(r io.Reader) (map[string]dotenvVar, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]dotenvVar)
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := i + 1
+		s := strings.TrimSpace(lines[i])
+		if s == "" || s[0] == '#' {
+			continue
+		}
+		if rest := strings.TrimPrefix(s, "export"); rest != s && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			s = strings.TrimSpace(rest)
+		}
+
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, errors.Errorf("line %d: expected KEY=VALUE", line)
+		}
+		key = strings.TrimSpace(key)
+		if !validEnvKey(key) {
+			return nil, errors.Errorf("line %d: invalid key %q", line, key)
+		}
+
+		value = strings.TrimLeft(value, " \t")
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote, rest := value[0], value[1:]
+			for {
+				v, tail, closed := unquoteDotenv(rest, quote)
+				if closed {
+					tail = strings.TrimSpace(tail)
+					if tail != "" && tail[0] != '#' {
+						return nil, errors.Errorf("line %d: unexpected %q after the closing quote", line, tail)
+					}
+					value = v
+					break
+				}
+				i++
+				if i == len(lines) {
+					return nil, errors.Errorf("line %d: unterminated quoted value", line)
+				}
+				rest += "\n" + lines[i]
+			}
+		} else {
+			if j := dotenvComment(value); j >= 0 {
+				value = value[:j]
+			}
+			value = strings.TrimSpace(value)
+		}
+
+		vars[key] = dotenvVar{value: value, line: line}
+	}
+	return vars, nil
+}
+
+func validEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c == '.', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unquoteDotenv returns the value up to the closing quote and whatever follows it, or false if the quote is not closed
+func unquoteDotenv(s string, quote byte) (string, string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), s[i+1:], true
+		case c == '\\' && quote == '"' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
+
+// dotenvComment returns the index of a # that starts a comment after an unquoted value
+func dotenvComment(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// decodeDotenv sets the options of cfg from the variables that match their env keys
+func decodeDotenv(cfg any, r io.Reader, strict bool) error {
+	vars, err := parseDotenv(r)
+	if err != nil {
+		return err
+	}
+
+	p := plainParser(cfg)
+	values := make(map[*flags.Option][]string)
+	used := make(map[string]bool, len(vars))
+
+	eachOption(p.Command, func(c *flags.Command, g *flags.Group, o *flags.Option) {
+		key := o.EnvKeyWithNamespace()
+		if v, ok := vars[key]; ok && key != "" {
+			values[o] = splitEnv(o, v.value)
+			used[key] = true
+		}
+	})
+
+	if strict {
+		var unknown []string
+		for key, v := range vars {
+			if !used[key] {
+				unknown = append(unknown, fmt.Sprintf("%s (line %d)", key, v.line))
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return unknownKeysError(unknown)
+		}
+	}
+
+	return setOptions(p, values)
+}
+
+// splitEnv splits an env value into the values of an option the same way go-flags does
+func splitEnv(o *flags.Option, value string) []string {
+	if o.EnvDefaultDelim != "" {
+		return strings.Split(value, o.EnvDefaultDelim)
+	}
+	return []string{value}
+}
+
+// loadEnvFiles reads the dotenv files of the EnvFiles and OptionalEnvFiles options, later files win
+func loadEnvFiles(copts *confOptions) (map[string]dotenvVar, error) {
+	env := make(map[string]dotenvVar)
+	for _, path := range copts.envFiles {
+		data, err := readConfigFile(copts, path)
+		if err != nil {
+			if path.optional && stderr.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to open required env file %s", path.path)
+		}
+		vars, err := parseDotenv(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse env file %s", path.path)
+		}
+		for key, v := range vars {
+			v.path = path.path
+			env[key] = v
+		}
+	}
+	return env, nil
+}
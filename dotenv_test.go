@@ -0,0 +1,128 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DotenvDecoder(t *testing.T) {
+	var tcs = map[string]struct {
+		data        string
+		expected    envDefaultOptions
+		expectedErr string
+	}{
+		"values": {
+			data: "TEST_I=3\nexport TEST_T = 13s\nTEST_S=4,5\nTEST_M=a:1;b:2\nNESTED_FOO=bar baz # comment\n",
+			expected: envDefaultOptions{
+				Int:    3,
+				Time:   13 * time.Second,
+				Map:    map[string]int{"a": 1, "b": 2},
+				Slice:  []int{4, 5},
+				Nested: envNestedOptions{Foo: "bar baz"},
+			},
+		},
+		"quotes": {
+			data: "NESTED_FOO='a #b \\n'\n",
+			expected: envDefaultOptions{
+				Map:    map[string]int{},
+				Nested: envNestedOptions{Foo: `a #b \n`},
+			},
+		},
+		"double quotes": {
+			data: "# comment\nNESTED_FOO=\"a\\tb\n\\\"c\\\"\" # comment\nOTHER=1\n",
+			expected: envDefaultOptions{
+				Map:    map[string]int{},
+				Nested: envNestedOptions{Foo: "a\tb\n\"c\""},
+			},
+		},
+		"missing separator": {
+			data:        "TEST_I=1\nTEST_T\n",
+			expectedErr: "failed to decode dotenv: line 2: expected KEY=VALUE",
+		},
+		"unterminated quote": {
+			data:        "NESTED_FOO=\"abc\nTEST_I=1\n",
+			expectedErr: "failed to decode dotenv: line 1: unterminated quoted value",
+		},
+		"text after quote": {
+			data:        "NESTED_FOO='a' b\n",
+			expectedErr: `failed to decode dotenv: line 1: unexpected "b" after the closing quote`,
+		},
+		"invalid key": {
+			data:        "1TEST=1\n",
+			expectedErr: `failed to decode dotenv: line 1: invalid key "1TEST"`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			cfg := new(envDefaultOptions)
+			err := conf.DotenvDecoder(cfg, strings.NewReader(tc.data))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, *cfg)
+		})
+	}
+}
+
+func Test_Load_DotenvFile(t *testing.T) {
+	cfg, err := conf.Load[envDefaultOptions](conf.Paths("testdata/config.env"), conf.Args([]string{}), conf.WithFlagOpts(flags.None))
+	require.NoError(t, err)
+	require.Equal(t, &envDefaultOptions{
+		Int:    3,
+		Time:   13 * time.Second,
+		Map:    map[string]int{"a": 1, "b": 2},
+		Slice:  []int{4, 5},
+		Nested: envNestedOptions{Foo: "multi\nline \"quoted\""},
+	}, cfg)
+
+	_, err = conf.Load[envDefaultOptions](conf.Paths("testdata/config.env"), conf.Args([]string{}), conf.WithFlagOpts(flags.None), conf.Strict())
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("TEST_I=3\nTEST_X=1\n"), 0o644))
+	_, err = conf.Load[envDefaultOptions](conf.Paths(path), conf.Args([]string{}), conf.WithFlagOpts(flags.None), conf.Strict())
+	require.EqualError(t, err, "failed to merge config file "+path+": failed to decode dotenv: unknown keys: TEST_X (line 2)")
+}
+
+func Test_Load_EnvFiles(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("TEST_I", "5")
+
+	cfg, report, err := conf.LoadWithReport[envDefaultOptions](
+		conf.EnvFiles("testdata/config.env"),
+		conf.OptionalEnvFiles("testdata/missing.env"),
+		conf.Args([]string{"--t=1s"}),
+		conf.WithFlagOpts(flags.None),
+	)
+	require.NoError(t, err)
+	require.Equal(t, &envDefaultOptions{
+		Int:    5,
+		Time:   time.Second,
+		Map:    map[string]int{"a": 1, "b": 2},
+		Slice:  []int{4, 5},
+		Nested: envNestedOptions{Foo: "multi\nline \"quoted\""},
+	}, cfg)
+
+	field, ok := report.Field("Int")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Env, Name: "TEST_I"}, field.Origin)
+
+	field, ok = report.Field("Slice")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Env, Name: "TEST_S (testdata/config.env)"}, field.Origin)
+
+	_, err = conf.Load[envDefaultOptions](conf.EnvFiles("testdata/missing.env"), conf.Args([]string{}), conf.WithFlagOpts(flags.None))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open required env file testdata/missing.env")
+}
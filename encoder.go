@@ -0,0 +1,115 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type EncoderFunc func(cfg any, w io.Writer) error
+
+func getEncoder(ext string) (EncoderFunc, error) {
+	enc, ok := DefaultEncoders[ext]
+	if !ok {
+		return nil, errors.Errorf("no encoder for %s", ext)
+	}
+	return enc, nil
+}
+
+var DefaultEncoders = map[string]EncoderFunc{
+	".yaml": YAMLEncoder,
+	".yml":  YAMLEncoder,
+	".json": JSONEncoder,
+	".toml": TOMLEncoder,
+}
+
+// Encode writes cfg to w in the format registered for ext, e.g. ".yaml"
+func Encode(cfg any, ext string, w io.Writer) error {
+	enc, err := getEncoder(ext)
+	if err != nil {
+		return err
+	}
+	return enc(cfg, w)
+}
+
+// Write writes cfg to the file at path in the format that matches its extension
+func Write(cfg any, path string) (err error) {
+	enc, err := getEncoder(filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create config file %s", path)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = errors.Wrapf(cerr, "failed to close config file %s", path)
+		}
+	}()
+
+	return errors.Wrapf(enc(cfg, f), "failed to write config file %s", path)
+}
+
+var YAMLEncoder = func(cfg any, w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(cfg); err != nil {
+		return errors.Wrap(err, "failed to encode yaml")
+	}
+	return errors.Wrap(enc.Close(), "failed to encode yaml")
+}
+
+var JSONEncoder = func(cfg any, w io.Writer) error {
+	return errors.Wrap(encodeJSON(cfg, w), "failed to encode json")
+}
+
+// LegacyJSONEncoder uses the yaml tags, it writes files that LegacyJSONDecoder reads back
+var LegacyJSONEncoder = func(cfg any, w io.Writer) error {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return errors.Wrap(err, "failed to encode json")
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return errors.Wrap(err, "failed to encode json")
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(stringKeys(v)), "failed to encode json")
+}
+
+var TOMLEncoder = func(cfg any, w io.Writer) error {
+	err := toml.NewEncoder(w).Encode(cfg)
+	return errors.Wrap(err, "failed to encode toml")
+}
+
+// stringKeys converts the maps with non-string keys that yaml.v3 produces into maps that JSON can represent
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = stringKeys(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+		return v
+	}
+	return v
+}
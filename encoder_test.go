@@ -0,0 +1,38 @@
+package conf_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Write_RoundTrip(t *testing.T) {
+	cfg, err := conf.Load[defaultOptions](conf.Paths("testdata/config.yaml"), conf.Args([]string{"--id=0", "--t=17s"}))
+	require.NoError(t, err)
+
+	for _, ext := range []string{".yaml", ".yml", ".json", ".toml"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config"+ext)
+			require.NoError(t, conf.Write(cfg, path))
+
+			loaded, err := conf.Load[defaultOptions](conf.Paths(path), conf.Args([]string{}))
+			require.NoError(t, err)
+			require.Equal(t, cfg, loaded)
+		})
+	}
+}
+
+func Test_Encode(t *testing.T) {
+	cfg := &defaultOptions{Int: 3, Time: 13_000_000_000}
+
+	var buf bytes.Buffer
+	require.NoError(t, conf.Encode(cfg, ".json", &buf))
+	require.Contains(t, buf.String(), `"time": "13s"`)
+	require.Contains(t, buf.String(), `"int": 3`)
+
+	err := conf.Encode(cfg, ".txt", &buf)
+	require.EqualError(t, err, "no encoder for .txt")
+}
@@ -0,0 +1,112 @@
+package conf
+
+import (
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
+)
+
+// derivedEnvKeys returns the env variables that EnvPrefix derives for the options without an env tag:
+// the prefix, the env namespace or else the namespace of every group and the long name or else the yaml key.
+// The config file flag gets none, an env variable would pick the config files otherwise.
+func derivedEnvKeys(copts *confOptions, p *flagParser) map[*flags.Option]string {
+	keys := make(map[*flags.Option]string)
+	if copts.envPrefix == "" {
+		return keys
+	}
+
+	var walk func(g *flags.Group, namespaces []string)
+	walk = func(g *flags.Group, namespaces []string) {
+		if g == p.config {
+			return
+		}
+		switch {
+		case g.EnvNamespace != "":
+			namespaces = append(namespaces[:len(namespaces):len(namespaces)], g.EnvNamespace)
+		case g.Namespace != "":
+			namespaces = append(namespaces[:len(namespaces):len(namespaces)], envName(g.Namespace))
+		}
+
+		for _, o := range g.Options() {
+			if o.Field().Tag.Get("env") != "" {
+				continue
+			}
+			name := o.LongName
+			if name == "" {
+				name, _, _ = yamlFieldName(o.Field())
+			}
+			keys[o] = strings.Join(append(namespaces[:len(namespaces):len(namespaces)], envName(name)), "_")
+		}
+		for _, gg := range g.Groups() {
+			walk(gg, namespaces)
+		}
+	}
+	eachCommand(p.Command, func(c *flags.Command) {
+		walk(c.Group, []string{envName(copts.envPrefix)})
+	}, true)
+	return keys
+}
+
+// envName turns a name like intDefault or server-tls into the INT_DEFAULT or SERVER_TLS form of env variables
+func envName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteByte('_')
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// lookupEnv looks up a variable in the environment and then in the env files
+func lookupEnv(env map[string]dotenvVar, key string) (string, bool) {
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true
+	}
+	if v, ok := env[key]; ok {
+		return v.value, true
+	}
+	return "", false
+}
+
+// envKey returns the env variable of an option, the one that EnvPrefix derived or else the one of its env tag
+func envKey(o *flags.Option, derived map[*flags.Option]string) string {
+	if key, ok := derived[o]; ok {
+		return key
+	}
+	return o.EnvKeyWithNamespace()
+}
+
+// lookupEnvFile reads the file that the variable of EnvFileSuffix names for key, e.g. DB_PASSWORD_FILE for DB_PASSWORD.
+// It fails if key is set as well.
+func lookupEnvFile(copts *confOptions, env map[string]dotenvVar, key string) (string, bool, error) {
+	if copts.envFileSuffix == "" || key == "" {
+		return "", false, nil
+	}
+	fileKey := key + copts.envFileSuffix
+	path, ok := lookupEnv(env, fileKey)
+	if !ok {
+		return "", false, nil
+	}
+	if _, ok := lookupEnv(env, key); ok {
+		return "", false, errors.Errorf("both %s and %s are set", key, fileKey)
+	}
+	v, err := readSecretFile(path)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to read the file of %s", fileKey)
+	}
+	return v, true, nil
+}
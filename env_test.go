@@ -0,0 +1,134 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type prefixNestedOptions struct {
+	Foo string `long:"foo" default:"z"`
+}
+
+type prefixServerOptions struct {
+	TLSCert string `long:"tls-cert"`
+}
+
+type prefixOptions struct {
+	Int      int                 `long:"i" yaml:"int"`
+	Name     string              `short:"n" yaml:"serviceName"`
+	Explicit string              `long:"explicit" env:"TEST_EXPLICIT"`
+	Slice    []int               `long:"slice" env-delim:","`
+	Verbose  bool                `long:"verbose"`
+	Nested   prefixNestedOptions `group:"nested" namespace:"nested"`
+	Server   prefixServerOptions `group:"server" namespace:"server" env-namespace:"SRV"`
+}
+
+func Test_Load_EnvPrefix(t *testing.T) {
+	var tcs = []struct {
+		msg      string
+		env      map[string]string
+		args     []string
+		expected prefixOptions
+	}{
+		{
+			msg:      "no env variables",
+			expected: prefixOptions{Nested: prefixNestedOptions{Foo: "z"}},
+		},
+		{
+			msg: "derived env variables",
+			env: map[string]string{
+				"MYAPP_I":            "2",
+				"MYAPP_SERVICE_NAME": "svc",
+				"MYAPP_SLICE":        "1,2",
+				"MYAPP_VERBOSE":      "true",
+				"MYAPP_NESTED_FOO":   "y",
+				"MYAPP_SRV_TLS_CERT": "cert.pem",
+			},
+			expected: prefixOptions{
+				Int:     2,
+				Name:    "svc",
+				Slice:   []int{1, 2},
+				Verbose: true,
+				Nested:  prefixNestedOptions{Foo: "y"},
+				Server:  prefixServerOptions{TLSCert: "cert.pem"},
+			},
+		},
+		{
+			msg: "explicit env tags win",
+			env: map[string]string{
+				"MYAPP_EXPLICIT": "derived",
+				"TEST_EXPLICIT":  "explicit",
+			},
+			expected: prefixOptions{Explicit: "explicit", Nested: prefixNestedOptions{Foo: "z"}},
+		},
+		{
+			msg:      "flags win over derived env variables",
+			env:      map[string]string{"MYAPP_I": "2", "MYAPP_NESTED_FOO": "y"},
+			args:     []string{"--i=3"},
+			expected: prefixOptions{Int: 3, Nested: prefixNestedOptions{Foo: "y"}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.msg, func(t *testing.T) {
+			oldEnv := EnvSnapshot()
+			defer oldEnv.Restore()
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+			}
+
+			cfg, err := conf.Load[prefixOptions](conf.EnvPrefix("MYAPP"), conf.Args(tc.args))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, *cfg)
+		})
+	}
+}
+
+func Test_Load_EnvPrefix_Report(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("MYAPP_I", "2")
+
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("MYAPP_NESTED_FOO=y\n"), 0o644))
+
+	cfg, report, err := conf.LoadWithReport[prefixOptions](conf.EnvPrefix("MYAPP"), conf.EnvFiles(path), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, 2, cfg.Int)
+	require.Equal(t, "y", cfg.Nested.Foo)
+
+	field, ok := report.Field("Int")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Env, Name: "MYAPP_I"}, field.Origin)
+
+	field, ok = report.Field("Nested.Foo")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Env, Name: "MYAPP_NESTED_FOO (" + path + ")"}, field.Origin)
+
+	// without the prefix the variables are ignored
+	cfg, err = conf.Load[prefixOptions](conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, 0, cfg.Int)
+}
+
+func Test_Load_EnvPrefix_ConfigFlag(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("int: 5\n"), 0o644))
+	os.Setenv("MYAPP_CONF", path)
+
+	// the env variable of the prefix does not pick the config files
+	cfg, err := conf.Load[prefixOptions](conf.EnvPrefix("MYAPP"), conf.ConfigFlag("conf"), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, 0, cfg.Int)
+
+	cfg, err = conf.Load[prefixOptions](conf.EnvPrefix("MYAPP"), conf.ConfigFlag("conf"), conf.Args([]string{"--conf=" + path}))
+	require.NoError(t, err)
+	require.Equal(t, 5, cfg.Int)
+}
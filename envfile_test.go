@@ -0,0 +1,106 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type envFileNestedOptions struct {
+	Token string `long:"token" env:"TOKEN"`
+}
+
+type envFileOptions struct {
+	Password string               `long:"password" env:"TEST_DB_PASSWORD" default:"none"`
+	Hosts    []string             `long:"host" env:"TEST_DB_HOSTS" env-delim:","`
+	Limits   map[string]int       `long:"limit" env:"TEST_DB_LIMITS" env-delim:";"`
+	Nested   envFileNestedOptions `group:"nested" namespace:"nested" env-namespace:"TEST_NESTED"`
+	Untagged string               `long:"untagged"`
+}
+
+func Test_Load_EnvFileSuffix(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	for _, key := range []string{"TEST_DB_PASSWORD", "TEST_DB_HOSTS", "TEST_DB_LIMITS", "TEST_NESTED_TOKEN"} {
+		os.Unsetenv(key)
+	}
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	os.Setenv("TEST_DB_PASSWORD_FILE", write("password", "s3cret\n"))
+	os.Setenv("TEST_DB_HOSTS_FILE", write("hosts", "h1,h2\n"))
+	os.Setenv("TEST_DB_LIMITS_FILE", write("limits", "a:1;b:2"))
+	os.Setenv("TEST_NESTED_TOKEN_FILE", write("token", "  t0ken  "))
+	os.Setenv("UNTAGGED_FILE", write("untagged", "x"))
+
+	cfg, report, err := conf.LoadWithReport[envFileOptions](conf.EnvFileSuffix("_FILE"), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, envFileOptions{
+		Password: "s3cret",
+		Hosts:    []string{"h1", "h2"},
+		Limits:   map[string]int{"a": 1, "b": 2},
+		Nested:   envFileNestedOptions{Token: "t0ken"},
+	}, *cfg)
+
+	field, ok := report.Field("Password")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Env, Name: "TEST_DB_PASSWORD_FILE"}, field.Origin)
+
+	// flags still win over the files
+	cfg, err = conf.Load[envFileOptions](conf.EnvFileSuffix("_FILE"), conf.Args([]string{"--password=flag"}))
+	require.NoError(t, err)
+	require.Equal(t, "flag", cfg.Password)
+
+	// without the option the variables are not read
+	cfg, err = conf.Load[envFileOptions](conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, "none", cfg.Password)
+}
+
+func Test_Load_EnvFileSuffix_EnvFiles(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Unsetenv("TEST_DB_PASSWORD")
+	os.Unsetenv("TEST_DB_PASSWORD_FILE")
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(secret, []byte("s3cret"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("TEST_DB_PASSWORD_FILE="+secret+"\n"), 0o644))
+
+	cfg, err := conf.Load[envFileOptions](
+		conf.EnvFiles(filepath.Join(dir, ".env")),
+		conf.EnvFileSuffix("_FILE"),
+		conf.Args([]string{}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", cfg.Password)
+}
+
+func Test_Load_EnvFileSuffix_Errors(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+
+	secret := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(secret, []byte("s3cret"), 0o600))
+
+	os.Setenv("TEST_DB_PASSWORD", "")
+	os.Setenv("TEST_DB_PASSWORD_FILE", secret)
+	_, err := conf.Load[envFileOptions](conf.EnvFileSuffix("_FILE"), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "both TEST_DB_PASSWORD and TEST_DB_PASSWORD_FILE are set")
+
+	os.Unsetenv("TEST_DB_PASSWORD")
+	os.Setenv("TEST_DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err = conf.Load[envFileOptions](conf.EnvFileSuffix("_FILE"), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to read the file of TEST_DB_PASSWORD_FILE")
+	require.Contains(t, err.Error(), "no such file or directory")
+}
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/go-chai/conf"
-	"gopkg.in/yaml.v3"
 )
 
 func main() {
@@ -17,7 +16,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to load config: %s", err)
 	}
-	yaml.NewEncoder(os.Stdout).Encode(cfg)
+	err = conf.Encode(cfg, ".yaml", os.Stdout)
+	if err != nil {
+		log.Fatalf("failed to encode config: %s", err)
+	}
 }
 
 type Config struct {
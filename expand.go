@@ -0,0 +1,186 @@
+package conf
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// expandExts are the formats whose content ExpandEnv interpolates, the other formats have their own quoting rules
+var expandExts = map[string]bool{".yaml": true, ".yml": true, ".json": true, ".json5": true, ".jsonc": true, ".toml": true}
+
+// expandable reports whether ExpandEnv interpolates a config file, files whose format is detected are interpolated
+// before the detection
+func expandable(copts *confOptions, path configPath) bool {
+	switch {
+	case path.format != "":
+		return expandExts[path.format]
+	case path.src != nil && path.src.layout != nil:
+		return false
+	case path.src != nil && path.src.http != nil:
+		ext := path.src.http.ext()
+		_, known := decoderByExt(copts, ext)
+		return expandExts[ext] || !known
+	case path.src != nil && path.src == copts.stdin:
+		return true
+	}
+	ext := filepath.Ext(path.path)
+	_, known := decoderByExt(copts, ext)
+	return expandExts[ext] || !known
+}
+
+// expandEnv replaces ${VAR} with the value of an env variable, ${VAR:-default} and ${VAR-default} with a default
+// if the variable is empty or unset, or only unset, and fails for ${VAR:?message} and ${VAR?message} in the same cases.
+// $$ is a literal $, and so is a $ that no { follows. Errors report the line in data and the variable.
+func expandEnv(data []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	if !bytes.Contains(data, []byte("$")) {
+		return data, nil
+	}
+
+	s := string(data)
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return nil, errors.Errorf("line %d: unterminated ${", lineAt(s, i))
+			}
+			v, err := expandVar(s[i+2:end], lookup)
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", lineAt(s, i))
+			}
+			b.WriteString(v)
+			i = end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// expandVar expands the inside of ${...}, defaults may contain references themselves
+func expandVar(expr string, lookup func(string) (string, bool)) (string, error) {
+	name, op, arg := expr, "", ""
+	if i := strings.IndexAny(expr, ":-?"); i >= 0 {
+		name, op, arg = expr[:i], expr[i:i+1], expr[i+1:]
+		if op == ":" {
+			if arg == "" || arg[0] != '-' && arg[0] != '?' {
+				return "", errors.Errorf("invalid reference ${%s}", expr)
+			}
+			op, arg = ":"+arg[:1], arg[1:]
+		}
+	}
+	if !validEnvKey(name) {
+		return "", errors.Errorf("invalid env variable name %q", name)
+	}
+
+	value, ok := lookup(name)
+	switch op {
+	case ":-", "-":
+		if !ok || op == ":-" && value == "" {
+			v, err := expandEnv([]byte(arg), lookup)
+			return string(v), err
+		}
+	case ":?", "?":
+		if !ok || op == ":?" && value == "" {
+			if arg == "" {
+				return "", errors.Errorf("required env variable %s is not set", name)
+			}
+			return "", errors.Errorf("required env variable %s is not set: %s", name, arg)
+		}
+	}
+	return value, nil
+}
+
+// closingBrace returns the index of the } that closes a ${ whose content starts at i, or -1
+func closingBrace(s string, i int) int {
+	depth := 1
+	for ; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func lineAt(s string, i int) int {
+	return strings.Count(s[:i], "\n") + 1
+}
+
+// expandTaggedFields interpolates the string values of the fields with an expand:"true" tag after they are decoded,
+// which works for every format and without ExpandEnv
+func expandTaggedFields(t reflect.Type, fields []field) []field {
+	var tagged []field
+	for _, f := range fields {
+		if t.FieldByIndex(f.index).Tag.Get("expand") == "true" {
+			tagged = append(tagged, f)
+		}
+	}
+	return tagged
+}
+
+func expandFields(cfg reflect.Value, fields []field, lookup func(string) (string, bool)) error {
+	for _, f := range fields {
+		v, ok := f.get(cfg)
+		if !ok {
+			continue
+		}
+		if err := expandValue(v, lookup); err != nil {
+			return errors.Wrapf(err, "field %s", f.path)
+		}
+	}
+	return nil
+}
+
+// expandValue interpolates strings and the strings in pointers, slices and map values
+func expandValue(v reflect.Value, lookup func(string) (string, bool)) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := expandEnv([]byte(v.String()), lookup)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(s))
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return expandValue(v.Elem(), lookup)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandValue(v.Index(i), lookup); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			s, err := expandEnv([]byte(iter.Value().String()), lookup)
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), reflect.ValueOf(string(s)).Convert(v.Type().Elem()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package conf
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v3"
+)
+
+// field is a leaf of the config struct, a value that every source sets as a whole
+type field struct {
+	path  string
+	index []int
+	typ   reflect.Type
+}
+
+func fieldsOf(t reflect.Type) []field {
+	var fields []field
+	collectFields(t, "", nil, map[reflect.Type]bool{}, &fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, prefix string, index []int, seen map[reflect.Type]bool, fields *[]field) {
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		path := joinPath(prefix, sf.Name)
+		idx := append(append([]int{}, index...), i)
+
+		if st, ok := nestedStruct(sf); ok && !seen[st] {
+			collectFields(st, path, idx, seen, fields)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		*fields = append(*fields, field{path: path, index: idx, typ: sf.Type})
+	}
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	flagUnmarshalerType = reflect.TypeOf((*flags.Unmarshaler)(nil)).Elem()
+)
+
+// nestedStruct reports whether the field holds options of its own rather than a single value
+func nestedStruct(sf reflect.StructField) (reflect.Type, bool) {
+	t := sf.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	if sf.Tag.Get("long") != "" || sf.Tag.Get("short") != "" {
+		return nil, false
+	}
+
+	pt := reflect.PtrTo(t)
+	for _, u := range []reflect.Type{textUnmarshalerType, jsonUnmarshalerType, yamlUnmarshalerType, flagUnmarshalerType} {
+		if pt.Implements(u) {
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// get returns the field of the struct v, or false if a nil pointer is in the way
+func (f field) get(v reflect.Value) (reflect.Value, bool) {
+	for _, i := range f.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// set assigns x to the field of the struct v, allocating nil pointers on the way
+func (f field) set(v reflect.Value, x reflect.Value) {
+	for _, i := range f.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	v.Set(x)
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+// sentinel returns a non-zero value of type t, used to detect fields that a decoder assigned
+func sentinel(t reflect.Type) (reflect.Value, bool) {
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Complex64, reflect.Complex128:
+		v.SetComplex(1)
+	case reflect.String:
+		v.SetString("-")
+	case reflect.Map:
+		m := reflect.MakeMap(t)
+		m.SetMapIndex(reflect.Zero(t.Key()), reflect.Zero(t.Elem()))
+		v.Set(m)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(t, 1, 1))
+	case reflect.Array:
+		e, ok := sentinel(t.Elem())
+		if t.Len() == 0 || !ok {
+			return v, false
+		}
+		v.Index(0).Set(e)
+	case reflect.Ptr:
+		e, ok := sentinel(t.Elem())
+		if !ok {
+			return v, false
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(e)
+		v.Set(p)
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return v, false
+		}
+		v.Set(reflect.ValueOf(true))
+	default:
+		return v, false
+	}
+	return v, true
+}
+
+// probeFields runs fill twice, once against a zero value and once against a value whose fields hold sentinels.
+// A field was assigned by fill if it is non-empty in the first run or lost its sentinel in the second one,
+// which also catches fields that were explicitly set to their zero value.
+func probeFields(t reflect.Type, fields []field, fill func(cfg any) error) (reflect.Value, map[string]bool, error) {
+	zero := reflect.New(t)
+	if err := fill(zero.Interface()); err != nil {
+		return zero, nil, err
+	}
+
+	marked := reflect.New(t)
+	for _, f := range fields {
+		if s, ok := sentinel(f.typ); ok {
+			f.set(marked, s)
+		}
+	}
+	if err := fill(marked.Interface()); err != nil {
+		return zero, nil, err
+	}
+
+	set := make(map[string]bool)
+	for _, f := range fields {
+		if v, ok := f.get(zero); ok && !isEmptyValue(v) {
+			set[f.path] = true
+			continue
+		}
+		s, ok := sentinel(f.typ)
+		if !ok {
+			continue
+		}
+		if v, ok := f.get(marked); ok && !reflect.DeepEqual(v.Interface(), s.Interface()) {
+			set[f.path] = true
+		}
+	}
+	return zero, set, nil
+}
+
+// nonZeroFields returns the paths of the fields of v that hold other values than their zero value
+func nonZeroFields(v reflect.Value, fields []field) map[string]bool {
+	set := make(map[string]bool)
+	for _, f := range fields {
+		if x, ok := f.get(v); ok && !isEmptyValue(x) {
+			set[f.path] = true
+		}
+	}
+	return set
+}
+
+// optionFields maps the options that the parser created for the config struct onto the paths of their fields
+func optionFields(p *flagParser, t reflect.Type) map[*flags.Option]string {
+	m := make(map[*flags.Option]string)
+	if groups := p.Groups(); len(groups) > 0 {
+		scanGroup(groups[0], t, "", m)
+	}
+	p.autoOptions(m)
+	return m
+}
+
+// scanGroup walks the struct of a group the same way go-flags does when it creates the options
+func scanGroup(g *flags.Group, t reflect.Type, prefix string, m map[*flags.Option]string) {
+	options, groups := g.Options(), g.Groups()
+
+	var scan func(t reflect.Type, prefix string)
+	scan = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous || sf.Tag.Get("no-flag") != "" {
+				continue
+			}
+
+			path := joinPath(prefix, sf.Name)
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				switch {
+				case sf.Tag.Get("group") != "":
+					if len(groups) > 0 {
+						scanGroup(groups[0], ft, path, m)
+						groups = groups[1:]
+					}
+				case sf.Tag.Get("command") != "", sf.Tag.Get("positional-args") != "":
+				default:
+					scan(ft, path)
+				}
+			}
+
+			if sf.Tag.Get("long") == "" && sf.Tag.Get("short") == "" && sf.Tag.Get("ini-name") == "" {
+				continue
+			}
+			if len(options) > 0 && options[0].Field().Name == sf.Name {
+				m[options[0]] = path
+				options = options[1:]
+			}
+		}
+	}
+	scan(t, prefix)
+}
@@ -0,0 +1,124 @@
+package conf
+
+import (
+	"bytes"
+	stderr "errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DetectOrder is the order in which DetectFormat tries the decoders, from the strictest syntax to the most lenient one
+var DetectOrder = []string{".json", ".json5", ".xml", ".toml", ".yaml", ".ini", ".env", ".properties"}
+
+// formatExt turns a format name like "yaml" into the extension its decoder is registered for
+func formatExt(format string) string {
+	if format == "" || strings.HasPrefix(format, ".") {
+		return format
+	}
+	return "." + format
+}
+
+// splitFormat separates an explicit format prefix like yaml:/etc/myapp/config from a path,
+// the prefix is only recognised if a decoder is registered for it
+func splitFormat(copts *confOptions, path configPath) configPath {
+	if path.format != "" {
+		return path
+	}
+	format, rest, ok := strings.Cut(path.path, ":")
+	if !ok || len(format) < 2 || strings.ContainsAny(format, `/\.`) {
+		return path
+	}
+	if _, ok := decoderByExt(copts, formatExt(format)); !ok {
+		return path
+	}
+	path.format = formatExt(format)
+	path.path = rest
+	return path
+}
+
+// decoderFor picks the decoder of a config file by its explicit format, the Content-Type of a URL, its extension
+// or, with DetectFormat, its content
+func decoderFor(copts *confOptions, t reflect.Type, path configPath, data []byte) (DecoderFunc, error) {
+	if path.format != "" {
+		dec, ok := decoderByExt(copts, path.format)
+		if !ok {
+			return nil, errors.Errorf("no decoder for %s", path.format)
+		}
+		return dec, nil
+	}
+
+	if path.src != nil && path.src.layout != nil {
+		return path.src.layout.decoder(copts), nil
+	}
+
+	// stdin has no extension, so its format is detected unless StdinFormat or a prefix sets it
+	if path.src != nil && path.src == copts.stdin {
+		return detectDecoder(copts, t, data)
+	}
+
+	if path.src != nil && path.src.http != nil {
+		ext := path.src.http.ext()
+		if dec, ok := decoderByExt(copts, ext); ok {
+			return dec, nil
+		}
+		if !copts.detectFormat {
+			return nil, errors.Errorf("no decoder for %s", ext)
+		}
+		return detectDecoder(copts, t, data)
+	}
+
+	dec, err := getDecoder(copts, path.path)
+	if err == nil || !copts.detectFormat {
+		return dec, err
+	}
+	return detectDecoder(copts, t, data)
+}
+
+// detectDecoder returns the decoder of the first format in DetectOrder that decodes data into a value of type t
+// without errors. The formats are tried in strict mode, because the lenient formats accept almost any content
+// when the keys that do not map to a field are ignored. If every format that reads data finds unknown keys, the one
+// with the fewest wins among those that set a field, and its unknown keys are only an error with the Strict option.
+func detectDecoder(copts *confOptions, t reflect.Type, data []byte) (DecoderFunc, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return func(cfg any, r io.Reader) error { return io.EOF }, nil
+	}
+
+	strict, lenient := *copts, *copts
+	strict.strict, lenient.strict = true, false
+	best, bestErr, fewest := "", error(nil), 0
+	for _, ext := range DetectOrder {
+		try, ok := decoderByExt(&strict, ext)
+		if !ok {
+			continue
+		}
+		err := try(reflect.New(t).Interface(), bytes.NewReader(data))
+		if err == nil || stderr.Is(err, io.EOF) {
+			dec, _ := decoderByExt(copts, ext)
+			return dec, nil
+		}
+		var unknown *unknownKeysErr
+		if !errors.As(err, &unknown) || best != "" && len(unknown.keys) >= fewest {
+			continue
+		}
+		// a format in which none of the keys maps to a field is not the format of data,
+		// the decoders that apply the default tags set the same fields for empty content
+		dec, _ := decoderByExt(&lenient, ext)
+		cfg, empty := reflect.New(t), reflect.New(t)
+		_ = dec(empty.Interface(), bytes.NewReader(nil))
+		if err := dec(cfg.Interface(), bytes.NewReader(data)); err != nil || reflect.DeepEqual(cfg.Interface(), empty.Interface()) {
+			continue
+		}
+		best, bestErr, fewest = ext, err, len(unknown.keys)
+	}
+	if best == "" {
+		return nil, errors.Errorf("failed to detect the format, none of %s decodes the content", strings.Join(DetectOrder, ", "))
+	}
+	if copts.strict {
+		return nil, bestErr
+	}
+	dec, _ := decoderByExt(copts, best)
+	return dec, nil
+}
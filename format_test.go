@@ -0,0 +1,97 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Load_DetectFormat(t *testing.T) {
+	expected := &defaultOptions{
+		Int:            3,
+		IntDefault:     13,
+		Float64:        2.712,
+		Float64Default: 1.1234,
+		String:         "asdf",
+		StringDefault:  "defg",
+		Time:           13_000_000_000,
+		TimeDefault:    660_000_000_000,
+		Map:            map[string]int{"val1": 3, "val2": 4},
+		MapDefault:     map[string]int{"val21": 21, "val22": 22},
+		Slice:          []int{1, 2, 3},
+		SliceDefault:   []int{4, 5, 6},
+	}
+
+	for _, name := range []string{"yaml", "json", "json5", "toml.conf", "xml", "ini.conf"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata/detect", name)
+
+			_, err := conf.Load[defaultOptions](conf.Paths(path), conf.Args([]string{}))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "no decoder for ")
+
+			cfg, err := conf.Load[defaultOptions](conf.Paths(path), conf.Args([]string{}), conf.DetectFormat())
+			require.NoError(t, err)
+			require.Equal(t, expected, cfg)
+		})
+	}
+}
+
+func Test_Load_DetectFormat_Errors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(empty, []byte("\n"), 0o644))
+	invalid := filepath.Join(dir, "invalid")
+	require.NoError(t, os.WriteFile(invalid, []byte("int: [\n"), 0o644))
+
+	_, err := conf.Load[defaultOptions](conf.Paths(empty), conf.Args([]string{}), conf.DetectFormat())
+	require.NoError(t, err)
+
+	_, err = conf.Load[defaultOptions](conf.Paths(invalid), conf.Args([]string{}), conf.DetectFormat())
+	require.EqualError(t, err, "failed to merge config file "+invalid+": failed to detect the format, none of .json, .json5, .xml, .toml, .yaml, .ini, .env, .properties decodes the content")
+}
+
+func Test_Load_DetectFormat_UnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte("int: 3\nextra: 1\n"), 0o644))
+
+	cfg, err := conf.Load[defaultOptions](conf.Paths(path), conf.Args([]string{}), conf.DetectFormat())
+	require.NoError(t, err)
+	require.Equal(t, 3, cfg.Int)
+
+	cfg, err = conf.Load[defaultOptions](conf.Paths("-"), conf.Args([]string{}), conf.Stdin(strings.NewReader("int: 4\nextra: 1\n")))
+	require.NoError(t, err)
+	require.Equal(t, 4, cfg.Int)
+
+	_, err = conf.Load[defaultOptions](conf.Paths(path), conf.Args([]string{}), conf.DetectFormat(), conf.Strict())
+	require.EqualError(t, err, "failed to merge config file "+path+": failed to decode yaml: unknown keys: extra (line 2)")
+}
+
+func Test_Load_ExplicitFormat(t *testing.T) {
+	var tcs = map[string][]conf.ConfOption{
+		"flag prefix":             {conf.ConfigFlag("conf"), conf.Args([]string{"--conf=yaml:testdata/detect/yaml"})},
+		"paths prefix":            {conf.Paths("toml:testdata/detect/toml.conf"), conf.Args([]string{})},
+		"format paths":            {conf.FormatPaths("json", "testdata/detect/json"), conf.Args([]string{})},
+		"overrides the extension": {conf.OptionalFormatPaths(".ini", "testdata/detect/ini.conf", "testdata/missing"), conf.AddDecoder(".conf", conf.YAMLDecoder), conf.Args([]string{})},
+	}
+
+	for name, opts := range tcs {
+		t.Run(name, func(t *testing.T) {
+			cfg, report, err := conf.LoadWithReport[defaultOptions](opts...)
+			require.NoError(t, err)
+			require.Equal(t, 3, cfg.Int)
+
+			field, ok := report.Field("Int")
+			require.True(t, ok)
+			require.Equal(t, conf.Files, field.Origin.Layer)
+			require.Contains(t, field.Origin.Name, "testdata/detect/")
+		})
+	}
+
+	_, err := conf.Load[defaultOptions](conf.FormatPaths("hcl", "testdata/detect/yaml"), conf.Args([]string{}))
+	require.EqualError(t, err, "failed to merge config file testdata/detect/yaml: no decoder for .hcl")
+}
@@ -0,0 +1,60 @@
+package conf_test
+
+import (
+	"embed"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/config.yaml
+var embedded embed.FS
+
+func Test_Load_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/config.yaml": {Data: []byte("int: 4\nstring: fs\n")},
+	}
+
+	cfg, err := conf.Load[defaultOptions](
+		conf.FS(fsys),
+		conf.Paths("/etc/config.yaml"),
+		conf.OptionalPaths("etc/missing.yaml"),
+		conf.Args([]string{}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 4, cfg.Int)
+	require.Equal(t, "fs", cfg.String)
+
+	_, err = conf.Load[defaultOptions](conf.FS(fsys), conf.ConfigFlag("conf"), conf.Args([]string{"--conf=etc/missing.yaml"}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open required config file etc/missing.yaml")
+}
+
+func Test_Load_FSPaths_Order(t *testing.T) {
+	cfg, report, err := conf.LoadWithReport[defaultOptions](
+		conf.FSPaths(embedded, "testdata/config.yaml"),
+		conf.OptionalFSPaths(embedded, "testdata/missing.yaml"),
+		conf.Reader("generated.json", strings.NewReader(`{"int": 5, "string": "reader"}`)),
+		conf.Bytes("toml", []byte("int = 6\n")),
+		conf.Args([]string{}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 6, cfg.Int)
+	require.Equal(t, "reader", cfg.String)
+	require.Equal(t, 13, cfg.IntDefault)
+
+	field, ok := report.Field("Int")
+	require.True(t, ok)
+	require.Equal(t, []conf.Setting{
+		{Origin: conf.Origin{Layer: conf.Files, Name: "testdata/config.yaml"}, Value: 3},
+		{Origin: conf.Origin{Layer: conf.Files, Name: "generated.json"}, Value: 5},
+	}, field.Overridden)
+	require.Equal(t, conf.Origin{Layer: conf.Files, Name: "<bytes>"}, field.Origin)
+
+	_, err = conf.Load[defaultOptions](conf.FSPaths(embedded, "testdata/missing.yaml"), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open required config file testdata/missing.yaml")
+}
@@ -0,0 +1,125 @@
+package conf
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ContentTypes maps the media types of HTTP responses to the extensions of their decoders
+var ContentTypes = map[string]string{
+	"application/json":       ".json",
+	"text/json":              ".json",
+	"application/json5":      ".json5",
+	"application/yaml":       ".yaml",
+	"application/x-yaml":     ".yaml",
+	"text/yaml":              ".yaml",
+	"text/x-yaml":            ".yaml",
+	"application/toml":       ".toml",
+	"text/toml":              ".toml",
+	"application/xml":        ".xml",
+	"text/xml":               ".xml",
+	"text/x-java-properties": ".properties",
+	"text/x-ini":             ".ini",
+}
+
+func isURL(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// httpSource fetches a config file from a URL, it remembers the last response so that the following requests
+// are conditional and a 304 Not Modified response reuses its body
+type httpSource struct {
+	url string
+
+	mu           sync.Mutex
+	data         []byte
+	etag         string
+	lastModified string
+	contentType  string
+}
+
+// urlSource returns the source of a URL, the same one for every load so that a Watcher polls with conditional requests
+func urlSource(copts *confOptions, u string) *pathSource {
+	src, ok := copts.urls[u]
+	if !ok {
+		src = &pathSource{http: &httpSource{url: u}}
+		copts.urls[u] = src
+	}
+	return src
+}
+
+func (s *httpSource) fetch(copts *confOptions) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), copts.httpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range copts.httpHeader {
+		req.Header[key] = append([]string{}, values...)
+	}
+	if s.data != nil {
+		if s.etag != "" {
+			req.Header.Set("If-None-Match", s.etag)
+		}
+		if s.lastModified != "" {
+			req.Header.Set("If-Modified-Since", s.lastModified)
+		}
+	}
+
+	resp, err := copts.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && s.data != nil:
+		return s.data, nil
+	case resp.StatusCode == http.StatusNotFound:
+		// a missing optional config file is skipped like a missing file
+		return nil, &fs.PathError{Op: "get", Path: s.url, Err: fs.ErrNotExist}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, errors.Errorf("get %s: %s", s.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.data = data
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	s.contentType = resp.Header.Get("Content-Type")
+	return data, nil
+}
+
+// ext returns the extension of the decoder for the last response, from its Content-Type or else from the URL path
+func (s *httpSource) ext() string {
+	s.mu.Lock()
+	contentType := s.contentType
+	s.mu.Unlock()
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if ext := ContentTypes[mediaType]; ext != "" {
+			return ext
+		}
+	}
+	if u, err := url.Parse(s.url); err == nil {
+		return path.Ext(u.Path)
+	}
+	return ""
+}
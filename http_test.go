@@ -0,0 +1,165 @@
+package conf_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+// configServer serves config files with an ETag and counts the requests that were answered with 304 Not Modified
+type configServer struct {
+	mu          sync.Mutex
+	files       map[string]string
+	contentType map[string]string
+	version     int
+	notModified int
+	headers     http.Header
+}
+
+func (s *configServer) set(path, contentType, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = data
+	s.contentType[path] = contentType
+	s.version++
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = r.Header.Clone()
+
+	switch r.URL.Path {
+	case "/slow":
+		time.Sleep(200 * time.Millisecond)
+	case "/error":
+		http.Error(w, "boom", http.StatusInternalServerError)
+		return
+	}
+
+	data, ok := s.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, s.version)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if ct := s.contentType[r.URL.Path]; ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	fmt.Fprint(w, data)
+}
+
+func newConfigServer(t *testing.T) (*configServer, *httptest.Server) {
+	s := &configServer{files: map[string]string{}, contentType: map[string]string{}}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func Test_Load_URL(t *testing.T) {
+	s, ts := newConfigServer(t)
+	s.set("/config", "application/json; charset=utf-8", `{"int": 4}`)
+	s.set("/config.yaml", "", "string: yaml\n")
+	s.set("/config.toml", "text/plain", "float64 = 1.5\n")
+
+	cfg, err := conf.Load[defaultOptions](
+		conf.Paths(ts.URL+"/config", ts.URL+"/config.yaml"),
+		conf.OptionalPaths(ts.URL+"/missing.yaml"),
+		conf.ConfigFlag("conf"),
+		conf.Args([]string{"--conf=" + ts.URL + "/config.toml"}),
+		conf.HTTPHeader("Authorization", "Bearer token"),
+		conf.ConsulToken("secret"),
+		conf.HTTPClient(ts.Client()),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 4, cfg.Int)
+	require.Equal(t, "yaml", cfg.String)
+	require.Equal(t, 1.5, cfg.Float64)
+	require.Equal(t, "Bearer token", s.headers.Get("Authorization"))
+	require.Empty(t, s.headers.Get("X-Consul-Token"))
+}
+
+func Test_Load_URL_Errors(t *testing.T) {
+	s, ts := newConfigServer(t)
+	s.set("/slow", "application/json", `{"int": 4}`)
+	s.set("/config.txt", "text/plain", "int: 4\n")
+
+	var tcs = map[string]struct {
+		opts        []conf.ConfOption
+		expectedErr string
+	}{
+		"missing": {
+			opts:        []conf.ConfOption{conf.Paths(ts.URL + "/missing.yaml")},
+			expectedErr: "failed to open required config file " + ts.URL + "/missing.yaml",
+		},
+		"status": {
+			opts:        []conf.ConfOption{conf.OptionalPaths(ts.URL + "/error")},
+			expectedErr: "get " + ts.URL + "/error: 500 Internal Server Error",
+		},
+		"timeout": {
+			opts:        []conf.ConfOption{conf.Paths(ts.URL + "/slow"), conf.HTTPTimeout(50 * time.Millisecond)},
+			expectedErr: "context deadline exceeded",
+		},
+		"format": {
+			opts:        []conf.ConfOption{conf.Paths(ts.URL + "/config.txt")},
+			expectedErr: "no decoder for .txt",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := conf.Load[defaultOptions](append(tc.opts, conf.Args([]string{}))...)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+
+	cfg, err := conf.Load[defaultOptions](conf.Paths("yaml:"+ts.URL+"/config.txt"), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, 4, cfg.Int)
+}
+
+func Test_Watch_URL(t *testing.T) {
+	s, ts := newConfigServer(t)
+	s.set("/config.yaml", "", "int: 1\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := conf.Watch[defaultOptions](ctx, conf.Paths(ts.URL+"/config.yaml"), conf.Args([]string{}), conf.PollInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, 1, w.Config().Int)
+
+	updates := make(chan *defaultOptions, 1)
+	w.Subscribe(func(cfg *defaultOptions) {
+		updates <- cfg
+	})
+
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.notModified >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.set("/config.yaml", "", "int: 2\n")
+	select {
+	case cfg := <-updates:
+		require.Equal(t, 2, cfg.Int)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
@@ -0,0 +1,34 @@
+package conf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
+)
+
+// decodeINI sets the options of cfg from an INI file with the IniParser of go-flags.
+// Sections select the groups by their group name and keys select options by their ini-name, field name,
+// long name with its namespace or short name. Without strict the keys and sections that match no option are ignored.
+func decodeINI(cfg any, r io.Reader, strict bool) error {
+	p := plainParser(cfg)
+	if !strict {
+		p.Options |= flags.IgnoreUnknown
+	}
+	return iniError(flags.NewIniParser(p.Parser).Parse(r))
+}
+
+// iniError turns the errors of the IniParser into the errors of the other decoders,
+// an unknown option is an unknown key with its line
+func iniError(err error) error {
+	var iniErr *flags.IniError
+	if !errors.As(err, &iniErr) {
+		return err
+	}
+	if name := strings.TrimPrefix(iniErr.Message, "unknown option: "); name != iniErr.Message {
+		return unknownKeysError([]string{fmt.Sprintf("%s (line %d)", name, iniErr.LineNumber)})
+	}
+	return errors.Errorf("line %d: %s", iniErr.LineNumber, iniErr.Message)
+}
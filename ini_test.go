@@ -0,0 +1,95 @@
+package conf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type iniNestedOptions struct {
+	Foo   string            `long:"foo"`
+	Hosts []string          `long:"host" ini-name:"hosts"`
+	Tags  map[string]string `long:"tag"`
+}
+
+type iniOptions struct {
+	Int    int              `long:"int"`
+	Nested iniNestedOptions `group:"Nested Options" namespace:"nested"`
+	Other  iniNestedOptions `group:"other"`
+}
+
+func Test_INIDecoder(t *testing.T) {
+	var tcs = map[string]struct {
+		data        string
+		expected    iniOptions
+		expectedErr string
+	}{
+		"sections": {
+			data: "int = 1\n\n[Nested Options]\nnested.foo = a\n; comment\nhosts = h1\nhosts = \"h2 \\\"x\\\"\"\nnested.tag = k1:v1\nnested.tag = k2:\"v 2\"\n\n[other]\nFoo = b\n",
+			expected: iniOptions{
+				Int:    1,
+				Nested: iniNestedOptions{Foo: "a", Hosts: []string{"h1", `h2 "x"`}, Tags: map[string]string{"k1": "v1", "k2": "v 2"}},
+				Other:  iniNestedOptions{Foo: "b"},
+			},
+		},
+		"group names are case insensitive": {
+			data:     "[nested options]\nFoo = a\n",
+			expected: iniOptions{Nested: iniNestedOptions{Foo: "a"}},
+		},
+		"namespaced keys": {
+			data:     "nested.foo = a\n",
+			expected: iniOptions{Nested: iniNestedOptions{Foo: "a"}},
+		},
+		"unknown keys": {
+			data:     "tiemout = 1\n[Nested Options]\nnested.foo = a\n[missing]\nx = 1\n",
+			expected: iniOptions{Nested: iniNestedOptions{Foo: "a"}},
+		},
+		"malformed section": {
+			data:        "[nested\n",
+			expectedErr: "failed to decode ini: line 1: malformed section header",
+		},
+		"missing separator": {
+			data:        "int = 1\nfoo\n",
+			expectedErr: "failed to decode ini: line 2: malformed key=value (foo)",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			cfg := new(iniOptions)
+			err := conf.INIDecoder(cfg, strings.NewReader(tc.data))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, *cfg)
+		})
+	}
+}
+
+func Test_Load_INI_Strict(t *testing.T) {
+	var tcs = map[string]struct {
+		data        string
+		expectedErr string
+	}{
+		"unknown key": {
+			data:        "int = 1\n[Nested Options]\nnested.foo = a\nbar = b\n",
+			expectedErr: "failed to decode ini: unknown keys: bar (line 4)",
+		},
+		"unknown section": {
+			data:        "int = 1\n[missing]\nx = 1\n",
+			expectedErr: "failed to decode ini: could not find option group `missing'",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := conf.Load[iniOptions](conf.Bytes(".ini", []byte(tc.data)), conf.Strict(), conf.Args([]string{}))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
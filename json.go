@@ -0,0 +1,345 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decodeJSON decodes JSON into cfg with encoding/json.
+// encoding/json only reads durations as nanoseconds, so the durations written as strings like "13s" are replaced
+// with numbers in the source before it is decoded. The offsets of the errors of encoding/json still point into
+// the source, and unknown keys are reported with their lines.
+func decodeJSON(cfg any, r io.Reader, strict bool) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	src := &jsonSource{data: data, d: json.NewDecoder(bytes.NewReader(data)), lines: map[string]int{}, spans: map[string]jsonSpan{}}
+	src.d.UseNumber()
+	tree, err := src.value("")
+	if err != nil {
+		return err
+	}
+
+	var spans []jsonSpan
+	w := &jsonWalker{strict: strict, duration: func(v any, path string) (any, error) {
+		x, err := parseJSONDuration(v, path)
+		if _, ok := v.(string); ok && err == nil {
+			n := x.(json.Number)
+			span := src.spans[path]
+			span.text = string(n)
+			spans = append(spans, span)
+		}
+		return x, err
+	}}
+	if _, err := w.walk(tree, reflect.TypeOf(cfg), ""); err != nil {
+		return err
+	}
+	if len(w.unknown) > 0 {
+		sort.Strings(w.unknown)
+		keys := make([]string, len(w.unknown))
+		for i, k := range w.unknown {
+			keys[i] = fmt.Sprintf("%s (line %d)", k, src.lines[k])
+		}
+		return unknownKeysError(keys)
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	err = json.NewDecoder(bytes.NewReader(spliceJSON(data, spans))).Decode(cfg)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		typeErr.Offset = sourceOffset(spans, typeErr.Offset)
+	}
+	return err
+}
+
+// jsonSource reads a JSON document token by token and records where its keys and strings are
+type jsonSource struct {
+	data []byte
+	d    *json.Decoder
+	// lines holds the line of every key by its path
+	lines map[string]int
+	// spans holds the bytes of every string value by its path
+	spans map[string]jsonSpan
+}
+
+// jsonSpan is a range of bytes of the source and the text that replaces it
+type jsonSpan struct {
+	start, end int64
+	text       string
+}
+
+// value reads the value at path into a tree of maps, slices and json.Numbers
+func (s *jsonSource) value(path string) (any, error) {
+	prev := s.d.InputOffset()
+	tok, err := s.d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			var list []any
+			for i := 0; s.d.More(); i++ {
+				v, err := s.value(path + "[" + strconv.Itoa(i) + "]")
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			_, err := s.d.Token()
+			return list, err
+		}
+		m := make(map[string]any)
+		for s.d.More() {
+			key, err := s.d.Token()
+			if err != nil {
+				return nil, err
+			}
+			k := key.(string)
+			s.lines[keyPath(path, k)] = bytes.Count(s.data[:s.d.InputOffset()], []byte("\n")) + 1
+			if m[k], err = s.value(keyPath(path, k)); err != nil {
+				return nil, err
+			}
+		}
+		_, err := s.d.Token()
+		return m, err
+	case string:
+		// only white space, colons and commas come before the quote
+		start := prev + int64(bytes.IndexByte(s.data[prev:], '"'))
+		s.spans[path] = jsonSpan{start: start, end: s.d.InputOffset()}
+	}
+	return tok, nil
+}
+
+// spliceJSON replaces the spans of data, which are sorted and do not overlap, with their texts
+func spliceJSON(data []byte, spans []jsonSpan) []byte {
+	if len(spans) == 0 {
+		return data
+	}
+	var buf bytes.Buffer
+	var pos int64
+	for _, s := range spans {
+		buf.Write(data[pos:s.start])
+		buf.WriteString(s.text)
+		pos = s.end
+	}
+	buf.Write(data[pos:])
+	return buf.Bytes()
+}
+
+// sourceOffset maps an offset into the spliced data back onto the source, an offset inside a replaced text
+// maps to the start of the span
+func sourceOffset(spans []jsonSpan, offset int64) int64 {
+	var delta int64
+	for _, s := range spans {
+		start := s.start + delta
+		if offset < start {
+			break
+		}
+		if offset < start+int64(len(s.text)) {
+			return s.start
+		}
+		delta += int64(len(s.text)) - (s.end - s.start)
+	}
+	return offset - delta
+}
+
+// decodeJSONTree decodes a tree of maps, slices and json.Numbers into cfg with the same field mapping as decodeJSON
+func decodeJSONTree(cfg any, tree any, strict bool) error {
+	w := &jsonWalker{strict: strict, duration: parseJSONDuration}
+	tree, err := w.walk(tree, reflect.TypeOf(cfg), "")
+	if err != nil {
+		return err
+	}
+	if len(w.unknown) > 0 {
+		sort.Strings(w.unknown)
+		return unknownKeysError(w.unknown)
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cfg)
+}
+
+// encodeJSON encodes cfg with encoding/json, writing durations as strings that decodeJSON reads back
+func encodeJSON(cfg any, w io.Writer) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	var tree any
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&tree); err != nil {
+		return err
+	}
+
+	tree, err = (&jsonWalker{duration: formatJSONDuration}).walk(tree, reflect.TypeOf(cfg), "")
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tree)
+}
+
+func parseJSONDuration(v any, path string) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid duration for %s", path)
+	}
+	return json.Number(strconv.FormatInt(int64(d), 10)), nil
+}
+
+func formatJSONDuration(v any, path string) (any, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid duration for %s", path)
+	}
+	return time.Duration(i).String(), nil
+}
+
+// jsonWalker visits a decoded JSON tree together with the Go types that encoding/json decodes its values into
+type jsonWalker struct {
+	strict   bool
+	unknown  []string
+	duration func(v any, path string) (any, error)
+}
+
+func (w *jsonWalker) walk(v any, t reflect.Type, path string) (any, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return w.duration(v, path)
+	}
+
+	pt := reflect.PtrTo(t)
+	if pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType) {
+		return v, nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v, nil
+		}
+		fields := jsonFields(t)
+		for k, e := range m {
+			ft, ok := fields.lookup(k)
+			if !ok {
+				if w.strict {
+					w.unknown = append(w.unknown, keyPath(path, k))
+				}
+				continue
+			}
+			if m[k], err = w.walk(e, ft, keyPath(path, k)); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v, nil
+		}
+		for k, e := range m {
+			if m[k], err = w.walk(e, t.Elem(), keyPath(path, k)); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := v.([]any)
+		if !ok {
+			return v, nil
+		}
+		for i, e := range s {
+			if s[i], err = w.walk(e, t.Elem(), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+type jsonFieldTypes map[string]reflect.Type
+
+// lookup matches a key to a field the way encoding/json does, preferring an exact match over a case-insensitive one
+func (fields jsonFieldTypes) lookup(key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// jsonFields returns the types of the fields of a struct by their JSON names, including the fields of embedded structs
+func jsonFields(t reflect.Type) jsonFieldTypes {
+	fields := make(jsonFieldTypes)
+	embedded := make(jsonFieldTypes)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for k, v := range jsonFields(ft) {
+				embedded[k] = v
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = sf.Type
+	}
+
+	// fields of the outer struct hide the fields of embedded structs
+	for k, v := range embedded {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
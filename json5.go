@@ -0,0 +1,350 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+)
+
+// decodeJSON5 decodes JSON5 and JSONC into cfg with the same field mapping as decodeJSON
+func decodeJSON5(cfg any, r io.Reader, strict bool) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	tree, err := parseJSON5(data)
+	if err != nil {
+		return err
+	}
+	return decodeJSONTree(cfg, tree, strict)
+}
+
+// parseJSON5 parses JSON with // and /* */ comments, trailing commas, unquoted keys, single quoted strings,
+// hexadecimal numbers and numbers with a leading plus or a leading or trailing decimal point.
+// Errors report the line and column in data.
+func parseJSON5(data []byte) (any, error) {
+	p := &json5Parser{data: data}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos == len(data) {
+		return nil, io.EOF
+	}
+
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(data) {
+		return nil, p.errorf("unexpected %q after the value", p.data[p.pos])
+	}
+	return v, nil
+}
+
+type json5Parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *json5Parser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+func (p *json5Parser) errorAt(pos int, format string, args ...any) error {
+	before := p.data[:pos]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return errors.Errorf("line %d, column %d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// skip skips whitespace and comments
+func (p *json5Parser) skip() error {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == ' ', c == '\t', c == '\n', c == '\r', c == '\f', c == '\v':
+			p.pos++
+		case bytes.HasPrefix(p.data[p.pos:], []byte("//")):
+			end := bytes.IndexByte(p.data[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.data)
+			} else {
+				p.pos += end + 1
+			}
+		case bytes.HasPrefix(p.data[p.pos:], []byte("/*")):
+			end := bytes.Index(p.data[p.pos+2:], []byte("*/"))
+			if end < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *json5Parser) value() (any, error) {
+	if p.pos == len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"', c == '\'':
+		return p.string()
+	case c == '-', c == '+', c == '.', c >= '0' && c <= '9':
+		return p.number()
+	}
+
+	start := p.pos
+	switch word := p.identifier(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		return nil, p.errorf("unexpected %q", p.data[p.pos])
+	default:
+		return nil, p.errorAt(start, "unexpected %q", word)
+	}
+}
+
+func (p *json5Parser) object() (any, error) {
+	p.pos++
+	m := make(map[string]any)
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == '}' {
+			p.pos++
+			return m, nil
+		}
+
+		var key string
+		if p.pos < len(p.data) && (p.data[p.pos] == '"' || p.data[p.pos] == '\'') {
+			k, err := p.string()
+			if err != nil {
+				return nil, err
+			}
+			key = k.(string)
+		} else if key = p.identifier(); key == "" {
+			return nil, p.errorf("expected a key")
+		}
+
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos == len(p.data) || p.data[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after the key %q", key)
+		}
+		p.pos++
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == '}' {
+			p.pos++
+			return m, nil
+		}
+		return nil, p.errorf("expected ',' or '}'")
+	}
+}
+
+func (p *json5Parser) array() (any, error) {
+	p.pos++
+	s := []any{}
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == ']' {
+			p.pos++
+			return s, nil
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == ']' {
+			p.pos++
+			return s, nil
+		}
+		return nil, p.errorf("expected ',' or ']'")
+	}
+}
+
+func (p *json5Parser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' && p.pos > start {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *json5Parser) string() (any, error) {
+	start := p.pos
+	quote := p.data[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for {
+		if p.pos == len(p.data) || p.data[p.pos] == '\n' {
+			return nil, p.errorAt(start, "unterminated string")
+		}
+
+		c := p.data[p.pos]
+		p.pos++
+		if c == quote {
+			return b.String(), nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+
+		if p.pos == len(p.data) {
+			return nil, p.errorAt(start, "unterminated string")
+		}
+		e := p.data[p.pos]
+		p.pos++
+		switch e {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '0':
+			b.WriteByte(0)
+		case '\r':
+			// a backslash at the end of a line continues the string on the next line
+			if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+				p.pos++
+			}
+		case '\n':
+		case 'u':
+			r, err := p.hex4()
+			if err != nil {
+				return nil, err
+			}
+			if utf16Surrogate(r) && bytes.HasPrefix(p.data[p.pos:], []byte(`\u`)) {
+				p.pos += 2
+				r2, err := p.hex4()
+				if err != nil {
+					return nil, err
+				}
+				r = (r-0xd800)<<10 + (r2 - 0xdc00) + 0x10000
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte(e)
+		}
+	}
+}
+
+func utf16Surrogate(r rune) bool {
+	return r >= 0xd800 && r < 0xdc00
+}
+
+func (p *json5Parser) hex4() (rune, error) {
+	if p.pos+4 > len(p.data) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	r, err := strconv.ParseUint(string(p.data[p.pos:p.pos+4]), 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	p.pos += 4
+	return rune(r), nil
+}
+
+// number returns a json.Number in the standard JSON syntax
+func (p *json5Parser) number() (any, error) {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	s := string(p.data[start:p.pos])
+	sign := ""
+	n := s
+	if n != "" && (n[0] == '-' || n[0] == '+') {
+		if n[0] == '-' {
+			sign = "-"
+		}
+		n = n[1:]
+	}
+
+	if strings.HasPrefix(n, "0x") || strings.HasPrefix(n, "0X") {
+		i, err := strconv.ParseUint(n[2:], 16, 64)
+		if err != nil {
+			return nil, p.errorAt(start, "invalid number %q", s)
+		}
+		return json.Number(sign + strconv.FormatUint(i, 10)), nil
+	}
+
+	if strings.HasPrefix(n, ".") {
+		n = "0" + n
+	}
+	n = strings.Replace(n, ".e", "e", 1)
+	n = strings.Replace(n, ".E", "E", 1)
+	n = strings.TrimSuffix(n, ".")
+	if _, err := strconv.ParseFloat(n, 64); err != nil || !json.Valid([]byte(n)) {
+		return nil, p.errorAt(start, "invalid number %q", s)
+	}
+	return json.Number(sign + n), nil
+}
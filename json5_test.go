@@ -0,0 +1,74 @@
+package conf_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_JSON5Decoder(t *testing.T) {
+	data := `{
+  // json tags are used like in .json files
+  name: 'it\'s é',
+  "timeout": "13s",
+  level: "debug", /* a json.Unmarshaler */
+  nested: {
+    timeouts: ['1s', 0x77359400,],
+    byName: {x: "1m"},
+  },
+}
+`
+	cfg := new(jsonOptions)
+	err := conf.JSON5Decoder(cfg, strings.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "it's é", cfg.Name)
+	require.Equal(t, 13*time.Second, cfg.Timeout)
+	require.Equal(t, jsonLevel(5), cfg.Level)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, cfg.Nested.Timeouts)
+	require.Equal(t, map[string]time.Duration{"x": time.Minute}, cfg.Nested.ByName)
+}
+
+func Test_JSON5Decoder_Errors(t *testing.T) {
+	var tcs = map[string]struct {
+		data        string
+		expectedErr string
+	}{
+		"missing comma": {
+			data:        "// comment\n{\n  name: 'a'\n  timeout: '1s'\n}\n",
+			expectedErr: "failed to decode json5: line 4, column 3: expected ',' or '}'",
+		},
+		"unterminated string": {
+			data:        "{\n  /* é */ name: 'a,\n}\n",
+			expectedErr: "failed to decode json5: line 2, column 17: unterminated string",
+		},
+		"unterminated comment": {
+			data:        "{name: 'a'} /* comment",
+			expectedErr: "failed to decode json5: line 1, column 13: unterminated comment",
+		},
+		"invalid number": {
+			data:        "{\n\tlevel: 1.2.3}",
+			expectedErr: `failed to decode json5: line 2, column 9: invalid number "1.2.3"`,
+		},
+		"unknown literal": {
+			data:        "{name: Infinity}",
+			expectedErr: `failed to decode json5: line 1, column 8: unexpected "Infinity"`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := conf.JSON5Decoder(new(jsonOptions), strings.NewReader(tc.data))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func Test_Load_JSONC_Strict(t *testing.T) {
+	data := `{"name": "a", "tiemout": "1s", "nested": {"byNmae": {},},}`
+	_, err := conf.Load[jsonOptions](conf.Bytes(".jsonc", []byte(data)), conf.Strict(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to decode jsonc: unknown keys: nested.byNmae, tiemout")
+}
@@ -0,0 +1,126 @@
+package conf_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonLevel int
+
+func (l *jsonLevel) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*l = jsonLevel(len(s))
+	return nil
+}
+
+func (l jsonLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.Repeat("x", int(l)))
+}
+
+type jsonOptions struct {
+	Name    string        `yaml:"yamlName" json:"name"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	Level   jsonLevel     `yaml:"level" json:"level"`
+	Nested  struct {
+		Timeouts []time.Duration          `yaml:"timeouts" json:"timeouts"`
+		ByName   map[string]time.Duration `yaml:"byName" json:"byName"`
+	} `yaml:"nested" json:"nested"`
+}
+
+func Test_JSONDecoder(t *testing.T) {
+	data := `{
+  "name": "a",
+  "timeout": "13s",
+  "level": "debug",
+  "nested": {"timeouts": ["1s", 2000000000], "byName": {"x": "1m"}}
+}`
+
+	cfg := new(jsonOptions)
+	err := conf.JSONDecoder(cfg, strings.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "a", cfg.Name)
+	require.Equal(t, 13*time.Second, cfg.Timeout)
+	require.Equal(t, jsonLevel(5), cfg.Level)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, cfg.Nested.Timeouts)
+	require.Equal(t, map[string]time.Duration{"x": time.Minute}, cfg.Nested.ByName)
+}
+
+func Test_JSONDecoder_Errors(t *testing.T) {
+	var tcs = map[string]struct {
+		data        string
+		expectedErr string
+	}{
+		"yaml syntax": {
+			data:        "level: debug\n",
+			expectedErr: "failed to decode json: invalid character 'l' looking for beginning of value",
+		},
+		"duration": {
+			data:        `{"nested": {"timeouts": ["1s", "2x"]}}`,
+			expectedErr: `failed to decode json: invalid duration for nested.timeouts[1]: time: unknown unit "x" in duration "2x"`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := conf.JSONDecoder(new(jsonOptions), strings.NewReader(tc.data))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func Test_JSONDecoder_ErrorOffset(t *testing.T) {
+	data := "{\n  \"timeout\": \"13s\",\n  \"name\": 1\n}"
+
+	err := conf.JSONDecoder(new(jsonOptions), strings.NewReader(data))
+	var typeErr *json.UnmarshalTypeError
+	require.True(t, errors.As(err, &typeErr))
+	require.Equal(t, int64(strings.Index(data, "1\n}")+1), typeErr.Offset)
+}
+
+func Test_JSONEncoder(t *testing.T) {
+	cfg := new(jsonOptions)
+	cfg.Name = "a"
+	cfg.Timeout = 13 * time.Second
+	cfg.Level = 3
+	cfg.Nested.Timeouts = []time.Duration{time.Second}
+
+	var buf bytes.Buffer
+	require.NoError(t, conf.JSONEncoder(cfg, &buf))
+	require.Contains(t, buf.String(), `"timeout": "13s"`)
+	require.Contains(t, buf.String(), `"timeouts": [
+      "1s"
+    ]`)
+
+	decoded := new(jsonOptions)
+	require.NoError(t, conf.JSONDecoder(decoded, &buf))
+	require.Equal(t, cfg, decoded)
+}
+
+func Test_Load_LegacyJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"yamlName": "a", "name": "b"}`), 0o644))
+
+	cfg, err := conf.Load[jsonOptions](conf.Paths(path), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, "b", cfg.Name)
+
+	cfg, err = conf.Load[jsonOptions](conf.Paths(path), conf.Args([]string{}), conf.LegacyJSON())
+	require.NoError(t, err)
+	require.Equal(t, "a", cfg.Name)
+
+	_, err = conf.Load[jsonOptions](conf.Paths(path), conf.Args([]string{}), conf.LegacyJSON(), conf.Strict())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown keys: name")
+}
@@ -1,20 +1,130 @@
 package conf
 
-import "github.com/jessevdk/go-flags"
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
+)
 
 type confOptions struct {
 	paths            []configPath
+	envFiles         []configPath
 	args             []string
 	delimiter        string
 	noValidation     bool
 	decoders         map[string]DecoderFunc
 	configFlagOption *flags.Option
 	flagOpts         flags.Options
+	pollInterval     time.Duration
+	strict           bool
+	legacyJSON       bool
+	detectFormat     bool
+	fsys             fs.FS
+	stdin            *pathSource
+	stdinFormat      string
+	urls             map[string]*pathSource
+	httpClient       *http.Client
+	httpHeader       http.Header
+	consulToken      string
+	httpTimeout      time.Duration
+	consulWait       time.Duration
+	sources          []Source
+	// err is the error of an invalid option, which load returns
+	err           error
+	envPrefix     string
+	autoFlags     bool
+	expandEnv     bool
+	envFileSuffix string
+	// resolvers holds the custom resolvers of secret references, secrets are resolved if it is not nil
+	resolvers map[string]ResolverFunc
 }
 
 type configPath struct {
 	path     string
 	optional bool
+	// format is the extension of the decoder to use instead of the one of the path
+	format string
+	// src reads the config file instead of the OS or FS file system if it is set
+	src *pathSource
+}
+
+// pathSource holds the file system or the content of a config file that was not given as an OS path
+type pathSource struct {
+	fsys   fs.FS
+	http   *httpSource
+	layout layoutSource
+
+	once sync.Once
+	r    io.Reader
+	data []byte
+	err  error
+}
+
+// layoutSource is a config source with a layout of its own, like a Consul prefix or a key-per-file directory,
+// that only its own decoder understands
+type layoutSource interface {
+	read(copts *confOptions, path string) ([]byte, error)
+	decoder(copts *confOptions) DecoderFunc
+}
+
+// read reads a Reader only once, so that a Watcher reloads the same content
+func (s *pathSource) read() ([]byte, error) {
+	s.once.Do(func() {
+		if s.r != nil {
+			s.data, s.err = io.ReadAll(s.r)
+			s.r = nil
+		}
+	})
+	return s.data, s.err
+}
+
+// sourceOrder returns the sources in the order they are merged
+func (o *confOptions) sourceOrder() []Source {
+	if o.sources == nil {
+		return defaultSources
+	}
+	return o.sources
+}
+
+// fail keeps the first error of the options
+func (o *confOptions) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// checkSources rejects the layers that are not built-in sources and built-in sources that are listed twice
+func checkSources(sources []Source) error {
+	seen := make(map[Layer]bool)
+	for _, s := range sources {
+		l, ok := s.(Layer)
+		if !ok {
+			continue
+		}
+		if !l.builtin() {
+			return errors.Errorf("the %s layer is not a built-in source", l)
+		}
+		if seen[l] {
+			return errors.Errorf("the %s source is listed twice", l)
+		}
+		seen[l] = true
+	}
+	return nil
+}
+
+// uses reports whether a built-in source is merged
+func (o *confOptions) uses(l Layer) bool {
+	for _, src := range o.sourceOrder() {
+		if src == Source(l) {
+			return true
+		}
+	}
+	return false
 }
 
 type ConfOption interface {
@@ -59,6 +169,184 @@ func OptionalPaths(paths ...string) ConfOption {
 	})
 }
 
+// FS makes Paths, OptionalPaths, EnvFiles and the ConfigFlag value read their files from fsys instead of the OS
+func FS(fsys fs.FS) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.fsys = fsys
+	})
+}
+
+// FSPaths adds config files that are read from fsys, e.g. an embed.FS with the defaults
+func FSPaths(fsys fs.FS, paths ...string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		for _, path := range paths {
+			o.paths = append(o.paths, configPath{path: path, src: &pathSource{fsys: fsys}})
+		}
+	})
+}
+
+func OptionalFSPaths(fsys fs.FS, paths ...string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		for _, path := range paths {
+			o.paths = append(o.paths, configPath{path: path, optional: true, src: &pathSource{fsys: fsys}})
+		}
+	})
+}
+
+// Reader adds a config file that is read from r, the extension of name selects the decoder
+func Reader(name string, r io.Reader) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.paths = append(o.paths, configPath{path: name, src: &pathSource{r: r}})
+	})
+}
+
+// Bytes adds a config file with the given content, ext selects the decoder, e.g. ".yaml" or "yaml"
+func Bytes(ext string, data []byte) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.paths = append(o.paths, configPath{path: "<bytes>", format: formatExt(ext), src: &pathSource{data: data}})
+	})
+}
+
+// HTTPClient sets the client that fetches the config files of http and https URLs, http.DefaultClient by default
+func HTTPClient(client *http.Client) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.httpClient = client
+	})
+}
+
+// HTTPHeader adds a header to the requests for the config files of URLs, e.g. an Authorization header.
+// The headers are not sent to Consul, its ACL token is set with ConsulToken.
+func HTTPHeader(key, value string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.httpHeader.Add(key, value)
+	})
+}
+
+// HTTPTimeout limits the time of every request for a config file, 30 seconds by default
+func HTTPTimeout(timeout time.Duration) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.httpTimeout = timeout
+	})
+}
+
+// Consul adds the keys under prefix in the Consul KV store at address as a config file, e.g.
+// Consul("http://127.0.0.1:8500", "myapp/"). The slashes of the keys below the prefix separate the namespaces
+// of the options like the Delimiter does for flags, and the prefix is a folder: myapp reads myapp/ but not myapp2/.
+// The requests use the HTTPClient and HTTPTimeout options, and the ACL token of ConsulToken.
+func Consul(address, prefix string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		src := newConsulSource(address, prefix)
+		o.paths = append(o.paths, configPath{path: src.url(), src: &pathSource{layout: src}})
+	})
+}
+
+// ConsulToken sets the ACL token of the requests to Consul, it is not sent to the config files of URLs
+func ConsulToken(token string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.consulToken = token
+	})
+}
+
+// OptionalConsul is like Consul but it skips the prefix if no key has it
+func OptionalConsul(address, prefix string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		src := newConsulSource(address, prefix)
+		o.paths = append(o.paths, configPath{path: src.url(), optional: true, src: &pathSource{layout: src}})
+	})
+}
+
+// Dir adds a directory where every file name is a key and its content the value as a config file, like the
+// ConfigMaps and secrets that Kubernetes mounts. Directories in it set nested structs or maps, and the names are
+// matched like the keys of a YAML file. The symlinks of Kubernetes volumes are followed, and names that start
+// with a dot are skipped.
+func Dir(path string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.paths = append(o.paths, configPath{path: path, src: &pathSource{layout: dirSource{}}})
+	})
+}
+
+// OptionalDir is like Dir but it skips the directory if it does not exist
+func OptionalDir(path string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.paths = append(o.paths, configPath{path: path, optional: true, src: &pathSource{layout: dirSource{}}})
+	})
+}
+
+// Credentials adds the credentials that systemd passes to a unit with LoadCredential= and SetCredential= in
+// $CREDENTIALS_DIRECTORY as a config file. A field with a credential:"db_password" tag reads the credential of that
+// name and fails the load if it is missing, unless the tag is credential:"db_password,optional". A tag without a name
+// uses the yaml keys of the field joined with dots. The other credentials set the fields like the files of Dir.
+func Credentials() ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.paths = append(o.paths, configPath{path: credentialsDir(), src: &pathSource{layout: credentialsSource{}}})
+	})
+}
+
+// ConsulWait sets how long the blocking queries of a Watcher wait for a change of the Consul keys, 5 minutes by default
+func ConsulWait(wait time.Duration) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.consulWait = wait
+	})
+}
+
+// Stdin sets the reader of the - path, which is os.Stdin by default
+func Stdin(r io.Reader) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.stdin = &pathSource{r: r}
+	})
+}
+
+// StdinFormat sets the format of the - path when it has no format prefix like yaml:-,
+// without it the format is detected from the content
+func StdinFormat(format string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.stdinFormat = formatExt(format)
+	})
+}
+
+// FormatPaths adds config files that are decoded as the given format, e.g. "yaml", whatever their extensions are
+func FormatPaths(format string, paths ...string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		for _, path := range paths {
+			o.paths = append(o.paths, configPath{path: path, format: formatExt(format)})
+		}
+	})
+}
+
+func OptionalFormatPaths(format string, paths ...string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		for _, path := range paths {
+			o.paths = append(o.paths, configPath{path: path, optional: true, format: formatExt(format)})
+		}
+	})
+}
+
+// DetectFormat detects the format of config files whose extensions have no decoder from their content.
+// It picks the first format in DetectOrder that decodes the file without errors and unknown keys, or else the format
+// with the fewest unknown keys, which are only an error with Strict.
+func DetectFormat() ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.detectFormat = true
+	})
+}
+
+// EnvFiles loads dotenv files into the env layer, variables that are set in the environment win over the files
+func EnvFiles(paths ...string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		for _, path := range paths {
+			o.envFiles = append(o.envFiles, configPath{path: path})
+		}
+	})
+}
+
+func OptionalEnvFiles(paths ...string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		for _, path := range paths {
+			o.envFiles = append(o.envFiles, configPath{path: path, optional: true})
+		}
+	})
+}
+
 func ConfigFlag(longName string, paths ...string) ConfOption {
 	return newFuncConfOption(func(o *confOptions) {
 		if o.configFlagOption == nil {
@@ -99,8 +387,146 @@ func AddDecoder(ext string, dec DecoderFunc) ConfOption {
 	})
 }
 
+// Strict makes the built-in decoders fail on config file keys that do not map to a field of the config
+func Strict() ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.strict = true
+	})
+}
+
+// LegacyJSON decodes .json files with yaml.v3 like older versions did, which uses the yaml tags instead of the json tags
+func LegacyJSON() ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.legacyJSON = true
+	})
+}
+
 func WithFlagOpts(flagOpts flags.Options) ConfOption {
 	return newFuncConfOption(func(o *confOptions) {
 		o.flagOpts = flagOpts
 	})
 }
+
+// PollInterval sets how often a Watcher checks the config files for changes
+func PollInterval(interval time.Duration) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.pollInterval = interval
+	})
+}
+
+func inLayers(layers []Layer, l Layer) bool {
+	for _, x := range layers {
+		if x == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources sets the sources and the order they are merged in, later sources win over earlier ones.
+// The built-in sources are Defaults, Files, Env and Flags, which is also the order without this option.
+// Custom sources go anywhere in between, e.g. Sources(Defaults, Files, db, Env, Flags).
+// A built-in source that is left out is not merged, but the command line is still parsed for the config flag and --help.
+func Sources(sources ...Source) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		if err := checkSources(sources); err != nil {
+			o.fail(errors.Wrap(err, "invalid sources"))
+			return
+		}
+		o.sources = append([]Source{}, sources...)
+	})
+}
+
+// Precedence reorders the built-in sources of Sources, or the default ones, later sources win over earlier ones.
+// For example Precedence(Defaults, Env, Files, Flags) makes config files win over env variables.
+// A built-in source that is left out is not merged. The custom sources keep following the same built-in source.
+func Precedence(layers ...Layer) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		sources := make([]Source, len(layers))
+		for i, l := range layers {
+			sources[i] = l
+		}
+		if err := checkSources(sources); err != nil {
+			o.fail(errors.Wrap(err, "invalid precedence"))
+			return
+		}
+
+		// the custom sources follow the built-in source they followed before, or the one before it
+		// if that is left out, or stay first
+		followers := make(map[Layer][]Source)
+		var last Layer
+		for _, s := range o.sourceOrder() {
+			if l, ok := s.(Layer); ok {
+				if inLayers(layers, l) {
+					last = l
+				}
+				continue
+			}
+			followers[last] = append(followers[last], s)
+		}
+		ordered := followers[0]
+		for _, l := range layers {
+			ordered = append(ordered, l)
+			ordered = append(ordered, followers[l]...)
+		}
+		o.sources = append([]Source{}, ordered...)
+	})
+}
+
+// EnvPrefix binds every option without an env tag to an env variable that is derived from the prefix,
+// the namespaces of its groups and its long name or else its yaml key, e.g. MYAPP_NESTED_FOO for the foo option
+// in the nested namespace. Groups with an env-namespace tag use it instead of the namespace.
+func EnvPrefix(prefix string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.envPrefix = prefix
+	})
+}
+
+// AutoFlags adds a long flag for every field without flag tags, named after its yaml key and the yaml keys
+// of the structs around it, joined with the Delimiter, e.g. --server-tls-cert. Groups add their namespaces as usual.
+// Fields with long, short or ini-name tags keep their flags, and a generated name that one of them uses is skipped.
+func AutoFlags() ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.autoFlags = true
+	})
+}
+
+// EnvFileSuffix makes every option with an env variable also read the file that the variable with the suffix names,
+// e.g. EnvFileSuffix("_FILE") reads DB_PASSWORD from the file in DB_PASSWORD_FILE like many container images do.
+// The content is trimmed and split at the env-delim of the option. Setting both variables is an error.
+func EnvFileSuffix(suffix string) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.envFileSuffix = suffix
+	})
+}
+
+// ExpandEnv replaces ${VAR}, ${VAR:-default} and ${VAR:?message} in YAML, JSON and TOML config files with env variables
+// before they are decoded, $$ stands for a literal $. The variables of EnvFiles count as env variables too.
+// Without ExpandEnv only the string fields with an expand:"true" tag are expanded, after they are decoded.
+func ExpandEnv() ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		o.expandEnv = true
+	})
+}
+
+// ResolveSecrets replaces string values that are references to secrets with the secrets, after the sources are merged
+// and before the config is validated. file:///run/secrets/db_password is the content of a file without the white space
+// around it, and env://DB_PASSWORD is an env variable or a variable of EnvFiles. Values of other schemes are left alone.
+func ResolveSecrets() ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		if o.resolvers == nil {
+			o.resolvers = make(map[string]ResolverFunc)
+		}
+	})
+}
+
+// AddResolver resolves the references of a scheme, e.g. vault://secret/db#password, with r. It replaces the built-in
+// resolver of the scheme and turns on ResolveSecrets.
+func AddResolver(scheme string, r ResolverFunc) ConfOption {
+	return newFuncConfOption(func(o *confOptions) {
+		if o.resolvers == nil {
+			o.resolvers = make(map[string]ResolverFunc)
+		}
+		o.resolvers[scheme] = r
+	})
+}
@@ -0,0 +1,138 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Load_Precedence(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("TEST_I", "2")
+	os.Setenv("TEST_T", "2s")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("int: 3\ntime: 3s\nslice: [3]\n"), 0o644))
+
+	type options struct {
+		Int   int           `long:"i" default:"1" env:"TEST_I" yaml:"int"`
+		Time  time.Duration `long:"t" default:"1s" env:"TEST_T" yaml:"time"`
+		Slice []int         `long:"s" default:"1" yaml:"slice"`
+	}
+
+	var tcs = []struct {
+		msg        string
+		precedence []conf.Layer
+		args       []string
+		expected   options
+	}{
+		{
+			msg:      "default precedence, env wins over files",
+			args:     []string{"--t=4s"},
+			expected: options{Int: 2, Time: 4 * time.Second, Slice: []int{3}},
+		},
+		{
+			msg:        "files win over env",
+			precedence: []conf.Layer{conf.Defaults, conf.Env, conf.Files, conf.Flags},
+			args:       []string{"--t=4s"},
+			expected:   options{Int: 3, Time: 4 * time.Second, Slice: []int{3}},
+		},
+		{
+			msg:        "env wins over flags",
+			precedence: []conf.Layer{conf.Defaults, conf.Files, conf.Flags, conf.Env},
+			args:       []string{"--t=4s", "--i=4"},
+			expected:   options{Int: 2, Time: 2 * time.Second, Slice: []int{3}},
+		},
+		{
+			msg:        "defaults win over everything",
+			precedence: []conf.Layer{conf.Files, conf.Env, conf.Flags, conf.Defaults},
+			args:       []string{"--s=4"},
+			expected:   options{Int: 1, Time: time.Second, Slice: []int{1}},
+		},
+		{
+			msg:        "left out layers are not merged",
+			precedence: []conf.Layer{conf.Defaults, conf.Flags},
+			args:       []string{"--t=4s"},
+			expected:   options{Int: 1, Time: 4 * time.Second, Slice: []int{1}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.msg, func(t *testing.T) {
+			opts := []conf.ConfOption{conf.Paths(path), conf.Args(tc.args)}
+			if tc.precedence != nil {
+				opts = append(opts, conf.Precedence(tc.precedence...))
+			}
+			cfg, err := conf.Load[options](opts...)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, *cfg)
+		})
+	}
+}
+
+func Test_Load_Precedence_Sources(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("TEST_I", "2")
+
+	type options struct {
+		Int int `long:"i" env:"TEST_I" yaml:"int" json:"int"`
+	}
+	tree := conf.TreeSource("tree", func() (map[string]any, error) {
+		return map[string]any{"int": 7}, nil
+	})
+	sources := conf.Sources(conf.Defaults, conf.Files, tree, conf.Env, conf.Flags)
+
+	cfg, err := conf.Load[options](conf.Args([]string{}), sources)
+	require.NoError(t, err)
+	require.Equal(t, 2, cfg.Int)
+
+	// the tree follows the files, so it moves with them behind the env variables
+	cfg, report, err := conf.LoadWithReport[options](
+		conf.Args([]string{}),
+		sources,
+		conf.Precedence(conf.Defaults, conf.Env, conf.Files, conf.Flags),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 7, cfg.Int)
+
+	field, ok := report.Field("Int")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Custom, Name: "tree"}, field.Origin)
+	require.Equal(t, []conf.Setting{{Origin: conf.Origin{Layer: conf.Env, Name: "TEST_I"}, Value: 2}}, field.Overridden)
+}
+
+func Test_Load_Precedence_Errors(t *testing.T) {
+	type options struct {
+		Int int `long:"i" default:"1"`
+	}
+
+	var tcs = []struct {
+		msg         string
+		precedence  []conf.Layer
+		expectedErr string
+	}{
+		{
+			msg:         "custom layer",
+			precedence:  []conf.Layer{conf.Defaults, conf.Custom, conf.Flags},
+			expectedErr: "invalid precedence: the source layer is not a built-in source",
+		},
+		{
+			msg:         "duplicate layer",
+			precedence:  []conf.Layer{conf.Defaults, conf.Flags, conf.Flags},
+			expectedErr: "invalid precedence: the flag source is listed twice",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.msg, func(t *testing.T) {
+			_, err := conf.Load[options](conf.Args([]string{}), conf.Precedence(tc.precedence...))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
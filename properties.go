@@ -0,0 +1,175 @@
+package conf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jessevdk/go-flags"
+)
+
+// property is a key and its value, from the line of a file if it has one
+type property struct {
+	key   string
+	value string
+	line  int
+}
+
+// parseProperties reads the Java .properties format: key=value, key: value or key value lines, # and ! comments,
+// lines continued with a trailing backslash, and the \t, \n, \r, \f and \uXXXX escapes
+func parseProperties(r io.Reader) ([]property, error) {
+	var values []property
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		start := line
+		text := strings.TrimLeft(s.Text(), " \t\f")
+		if text == "" || text[0] == '#' || text[0] == '!' {
+			continue
+		}
+
+		for continued(text) {
+			text = text[:len(text)-1]
+			if !s.Scan() {
+				break
+			}
+			line++
+			text += strings.TrimLeft(s.Text(), " \t\f")
+		}
+
+		key, value := splitProperty(text)
+		k, err := unescapeProperty(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", start)
+		}
+		v, err := unescapeProperty(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", start)
+		}
+		values = append(values, property{key: k, value: v, line: start})
+	}
+	return values, s.Err()
+}
+
+// continued reports whether a line ends with an odd number of backslashes
+func continued(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitProperty splits a line at the first unescaped =, : or whitespace, the value keeps its escapes
+func splitProperty(line string) (string, string) {
+	i := 0
+	for ; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+	}
+	if i >= len(line) {
+		return line, ""
+	}
+
+	key, rest := line[:i], strings.TrimLeft(line[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return key, rest
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", errors.Errorf("invalid unicode escape %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", errors.Errorf("invalid unicode escape %q", s[i-1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// decodeProperties sets the options of cfg whose namespaced long names match the keys.
+// The dots of a key separate namespaces, so server.tls.cert matches the --server-tls-cert flag
+// when the delimiter is "-". Repeated keys fill slices and maps like repeated flags.
+func decodeProperties(cfg any, r io.Reader, delimiter string, strict bool) error {
+	props, err := parseProperties(r)
+	if err != nil {
+		return err
+	}
+	p := plainParser(cfg)
+	p.NamespaceDelimiter = delimiter
+	return setNamespacedOptions(p, props, ".", strict)
+}
+
+// setNamespacedOptions sets the options of a parser from keys whose sep separates the namespaces of the options
+func setNamespacedOptions(p *flagParser, props []property, sep string, strict bool) error {
+	delimiter := p.NamespaceDelimiter
+	options := make(map[string]*flags.Option)
+	eachOption(p.Command, func(c *flags.Command, g *flags.Group, o *flags.Option) {
+		if o.LongName != "" {
+			options[o.LongNameWithNamespace()] = o
+		}
+	})
+
+	values := make(map[*flags.Option][]string)
+	var unknown []string
+	for _, prop := range props {
+		o, ok := options[strings.ReplaceAll(prop.key, sep, delimiter)]
+		if !ok {
+			o, ok = options[prop.key]
+		}
+		if !ok {
+			if prop.line > 0 {
+				unknown = append(unknown, fmt.Sprintf("%s (line %d)", prop.key, prop.line))
+			} else {
+				unknown = append(unknown, prop.key)
+			}
+			continue
+		}
+		values[o] = append(values[o], prop.value)
+	}
+
+	if strict && len(unknown) > 0 {
+		return unknownKeysError(unknown)
+	}
+
+	return setOptions(p, values)
+}
@@ -0,0 +1,81 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/require"
+)
+
+type propertiesTLSOptions struct {
+	Cert string `long:"cert"`
+}
+
+type propertiesServerOptions struct {
+	TLS   propertiesTLSOptions `group:"tls" namespace:"tls"`
+	Hosts []string             `long:"host"`
+	Tags  map[string]string    `long:"tag"`
+}
+
+type propertiesOptions struct {
+	Name   string                  `long:"name"`
+	Server propertiesServerOptions `group:"server" namespace:"server"`
+}
+
+const testProperties = `# comment
+! another comment
+name = caf\u00e9
+server.tls.cert: /etc/tls/cert.pem
+server.host h1
+server.host = h2, \
+              h3
+server.tag=env:prod
+server.tag = key\=x:a\tb
+`
+
+func Test_PropertiesDecoder(t *testing.T) {
+	cfg := new(propertiesOptions)
+	err := conf.PropertiesDecoder(cfg, strings.NewReader(testProperties))
+	require.NoError(t, err)
+	require.Equal(t, &propertiesOptions{
+		Name: "café",
+		Server: propertiesServerOptions{
+			TLS:   propertiesTLSOptions{Cert: "/etc/tls/cert.pem"},
+			Hosts: []string{"h1", "h2, h3"},
+			Tags:  map[string]string{"env": "prod", "key=x": "a\tb"},
+		},
+	}, cfg)
+}
+
+func Test_PropertiesDecoder_Errors(t *testing.T) {
+	err := conf.PropertiesDecoder(new(propertiesOptions), strings.NewReader("name=a\nserver.host=\\u12x4\n"))
+	require.EqualError(t, err, `failed to decode properties: line 2: invalid unicode escape "\\u12x4"`)
+
+	_, err = conf.Load[propertiesOptions](conf.Bytes(".properties", []byte("name=a\nserver.tls.crt=b\nport=1\n")), conf.Strict(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to decode properties: unknown keys: server.tls.crt (line 2), port (line 3)")
+}
+
+func Test_Load_Properties_Delimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.properties")
+	require.NoError(t, os.WriteFile(path, []byte(testProperties), 0o644))
+
+	for _, delimiter := range []string{"-", ".", "_"} {
+		t.Run(delimiter, func(t *testing.T) {
+			cfg, err := conf.Load[propertiesOptions](
+				conf.Paths(path),
+				conf.Delimiter(delimiter),
+				conf.Args([]string{"--server" + delimiter + "host=h4"}),
+				conf.WithFlagOpts(flags.None),
+				conf.Strict(),
+			)
+			require.NoError(t, err)
+			require.Equal(t, "/etc/tls/cert.pem", cfg.Server.TLS.Cert)
+			require.Equal(t, []string{"h4"}, cfg.Server.Hosts)
+		})
+	}
+}
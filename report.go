@@ -0,0 +1,94 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Layer is the kind of source that a config value was loaded from
+type Layer int
+
+const (
+	Defaults Layer = iota + 1
+	Files
+	Env
+	Flags
+	// Custom is the layer of the sources that Sources adds
+	Custom
+)
+
+func (l Layer) String() string {
+	switch l {
+	case Defaults:
+		return "default"
+	case Files:
+		return "file"
+	case Env:
+		return "env"
+	case Flags:
+		return "flag"
+	case Custom:
+		return "source"
+	}
+	return "unset"
+}
+
+// Origin identifies a single source: the flag of a default or command line value, an env variable or a config file path
+type Origin struct {
+	Layer Layer
+	Name  string
+}
+
+func (o Origin) String() string {
+	if o.Name == "" {
+		return o.Layer.String()
+	}
+	return o.Layer.String() + " " + o.Name
+}
+
+// Setting is a value of a field and the source it came from
+type Setting struct {
+	Origin Origin
+	Value  any
+}
+
+// FieldReport describes how the final value of a config field was chosen
+type FieldReport struct {
+	// Path is the Go path of the field, e.g. "Nested.Foo"
+	Path string
+	// Setting holds the final value and the source that won, its Origin is zero if no source set the field
+	Setting
+	// Overridden holds the values of the sources that lost, in the order they were merged
+	Overridden []Setting
+	// Merged holds the maps of the config files whose keys the map of Setting was merged with
+	Merged []Setting
+}
+
+// Report records where the final value of every config field came from
+type Report struct {
+	Fields []FieldReport
+}
+
+func (r *Report) Field(path string) (FieldReport, bool) {
+	for _, f := range r.Fields {
+		if f.Path == path {
+			return f, true
+		}
+	}
+	return FieldReport{}, false
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, f := range r.Fields {
+		fmt.Fprintf(&b, "%s = %v (%s)", f.Path, f.Value, f.Origin)
+		for _, o := range f.Overridden {
+			fmt.Fprintf(&b, ", overrides %v (%s)", o.Value, o.Origin)
+		}
+		for _, o := range f.Merged {
+			fmt.Fprintf(&b, ", merges %v (%s)", o.Value, o.Origin)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
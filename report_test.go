@@ -0,0 +1,108 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadWithReport(t *testing.T) {
+	cfg, report, err := conf.LoadWithReport[defaultOptions](
+		conf.Paths("testdata/config.yaml"),
+		conf.Args([]string{"--id=5", "--td=19s"}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 5, cfg.IntDefault)
+
+	var tcs = map[string]conf.FieldReport{
+		"Int": {
+			Path:    "Int",
+			Setting: conf.Setting{Origin: conf.Origin{Layer: conf.Files, Name: "testdata/config.yaml"}, Value: 3},
+		},
+		"IntDefault": {
+			Path:    "IntDefault",
+			Setting: conf.Setting{Origin: conf.Origin{Layer: conf.Flags, Name: "--id"}, Value: 5},
+			Overridden: []conf.Setting{
+				{Origin: conf.Origin{Layer: conf.Defaults, Name: "--id"}, Value: 1},
+				{Origin: conf.Origin{Layer: conf.Files, Name: "testdata/config.yaml"}, Value: 13},
+			},
+		},
+		"TimeDefault": {
+			Path:    "TimeDefault",
+			Setting: conf.Setting{Origin: conf.Origin{Layer: conf.Flags, Name: "--td"}, Value: 19 * time.Second},
+			Overridden: []conf.Setting{
+				{Origin: conf.Origin{Layer: conf.Defaults, Name: "--td"}, Value: time.Minute},
+				{Origin: conf.Origin{Layer: conf.Files, Name: "testdata/config.yaml"}, Value: 11 * time.Minute},
+			},
+		},
+		"StringNotUnquoted": {
+			Path:    "StringNotUnquoted",
+			Setting: conf.Setting{Value: ""},
+		},
+	}
+
+	for path, expected := range tcs {
+		t.Run(path, func(t *testing.T) {
+			field, ok := report.Field(path)
+			require.True(t, ok)
+			require.Equal(t, expected, field)
+		})
+	}
+}
+
+func Test_LoadWithReport_DefaultsAndEnv(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("TEST_I", "2")
+	os.Setenv("NESTED_FOO", "a")
+
+	_, report, err := conf.LoadWithReport[envDefaultOptions](conf.WithFlagOpts(flags.None), conf.Args([]string{"--nested.foo=b"}), conf.Delimiter("."))
+	require.NoError(t, err)
+
+	field, ok := report.Field("Int")
+	require.True(t, ok)
+	require.Equal(t, conf.Setting{Origin: conf.Origin{Layer: conf.Env, Name: "TEST_I"}, Value: 2}, field.Setting)
+
+	field, ok = report.Field("Time")
+	require.True(t, ok)
+	require.Equal(t, conf.Setting{Origin: conf.Origin{Layer: conf.Defaults, Name: "--t"}, Value: time.Minute}, field.Setting)
+
+	field, ok = report.Field("Nested.Foo")
+	require.True(t, ok)
+	require.Equal(t, conf.Setting{Origin: conf.Origin{Layer: conf.Flags, Name: "--nested.foo"}, Value: "b"}, field.Setting)
+	require.Equal(t, []conf.Setting{
+		{Origin: conf.Origin{Layer: conf.Defaults, Name: "--nested.foo"}, Value: "z"},
+		{Origin: conf.Origin{Layer: conf.Env, Name: "NESTED_FOO"}, Value: "a"},
+	}, field.Overridden)
+
+	require.Contains(t, report.String(), "Nested.Foo = b (flag --nested.foo), overrides z (default --nested.foo), overrides a (env NESTED_FOO)\n")
+}
+
+func Test_LoadWithReport_MapMerge(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	require.NoError(t, os.WriteFile(a, []byte("map: {x: 1, y: 1}\nmapDefault: {x: 1}\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("map: {y: 2}\n"), 0o644))
+
+	cfg, report, err := conf.LoadWithReport[defaultOptions](conf.Paths(a, b), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"x": 1, "y": 2}, cfg.Map)
+	require.Equal(t, map[string]int{"x": 1}, cfg.MapDefault)
+
+	field, ok := report.Field("Map")
+	require.True(t, ok)
+	require.Equal(t, conf.FieldReport{
+		Path:    "Map",
+		Setting: conf.Setting{Origin: conf.Origin{Layer: conf.Files, Name: b}, Value: map[string]int{"x": 1, "y": 2}},
+		Merged:  []conf.Setting{{Origin: conf.Origin{Layer: conf.Files, Name: a}, Value: map[string]int{"x": 1, "y": 1}}},
+	}, field)
+
+	cfg, err = conf.Load[defaultOptions](conf.Paths(a, b), conf.Args([]string{"--m=z:3"}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"z": 3}, cfg.Map)
+}
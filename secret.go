@@ -0,0 +1,138 @@
+package conf
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ResolverFunc returns the secret that a reference points to, ref is the part of the reference after scheme://.
+// Its errors must not contain the secret.
+type ResolverFunc func(ref string) (string, error)
+
+// defaultResolvers resolve file:///path to the content of a file and env://VAR to an env variable,
+// the variables of EnvFiles count as env variables too
+func defaultResolvers(env map[string]dotenvVar) map[string]ResolverFunc {
+	return map[string]ResolverFunc{
+		"file": readSecretFile,
+		"env": func(key string) (string, error) {
+			v, ok := lookupEnv(env, key)
+			if !ok {
+				return "", errors.Errorf("env variable %s is not set", key)
+			}
+			return v, nil
+		},
+	}
+}
+
+// readSecretFile reads a file that holds a single secret, without the white space around it
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ExecResolver runs the command of an exec://command args... reference and resolves it to the output of the command.
+// The arguments are split at white space. It is not registered by default, AddResolver("exec", ExecResolver) does that.
+func ExecResolver(ref string) (string, error) {
+	args := strings.Fields(ref)
+	if len(args) == 0 {
+		return "", errors.New("no command")
+	}
+	// the error of a failed command only holds its exit status, its output might contain the secret
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "command %s failed", args[0])
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// resolveSecrets replaces the references in the string values of the fields with the secrets they point to.
+// Strings in pointers, slices and map values are resolved too. The values are copied instead of changed in place,
+// so the report keeps the references.
+func resolveSecrets(cfg reflect.Value, fields []field, resolvers map[string]ResolverFunc) error {
+	resolve := func(s string) (string, error) {
+		scheme, ref, ok := strings.Cut(s, "://")
+		if !ok {
+			return s, nil
+		}
+		r, ok := resolvers[scheme]
+		if !ok {
+			return s, nil
+		}
+		secret, err := r(ref)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to resolve the %s:// reference", scheme)
+		}
+		return secret, nil
+	}
+
+	for _, f := range fields {
+		v, ok := f.get(cfg)
+		if !ok {
+			continue
+		}
+		nv, err := resolveValue(v, resolve)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", f.path)
+		}
+		f.set(cfg, nv)
+	}
+	return nil
+}
+
+// resolveValue returns a copy of v with its strings resolved
+func resolveValue(v reflect.Value, resolve func(string) (string, error)) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := resolve(v.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(s).Convert(v.Type()), nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		e, err := resolveValue(v.Elem(), resolve)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(e)
+		return p, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := resolveValue(v.Index(i), resolve)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Index(i).Set(e)
+		}
+		return s, nil
+	case reflect.Map:
+		if v.IsNil() || v.Type().Elem().Kind() != reflect.String {
+			return v, nil
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e, err := resolveValue(iter.Value(), resolve)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(iter.Key(), e)
+		}
+		return m, nil
+	}
+	return v, nil
+}
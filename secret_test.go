@@ -0,0 +1,129 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type secretOptions struct {
+	Password string            `yaml:"password" validate:"len=6"`
+	Token    *string           `yaml:"token"`
+	Keys     []string          `yaml:"keys"`
+	Headers  map[string]string `yaml:"headers"`
+	URL      string            `yaml:"url"`
+}
+
+func Test_Load_ResolveSecrets(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("DB_PASSWORD", "s3cret")
+	os.Unsetenv("API_KEY")
+
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(secretPath, []byte("t0ken\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("API_KEY=dotenv\n"), 0o644))
+
+	vault := func(ref string) (string, error) {
+		return "vault:" + ref, nil
+	}
+	content := strings.Join([]string{
+		"password: env://DB_PASSWORD",
+		"token: file://" + secretPath,
+		"keys: [env://API_KEY, plain, \"vault://secret/db#key\"]",
+		"headers:",
+		"  auth: exec://echo  h3ader",
+		"url: https://example.com",
+	}, "\n")
+
+	cfg, report, err := conf.LoadWithReport[secretOptions](
+		conf.EnvFiles(filepath.Join(dir, ".env")),
+		conf.Bytes("yaml", []byte(content)),
+		conf.ResolveSecrets(),
+		conf.AddResolver("vault", vault),
+		conf.AddResolver("exec", conf.ExecResolver),
+		conf.Args([]string{}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", cfg.Password)
+	require.Equal(t, "t0ken", *cfg.Token)
+	require.Equal(t, []string{"dotenv", "plain", "vault:secret/db#key"}, cfg.Keys)
+	require.Equal(t, map[string]string{"auth": "h3ader"}, cfg.Headers)
+	require.Equal(t, "https://example.com", cfg.URL)
+
+	// the report keeps the references
+	field, ok := report.Field("Password")
+	require.True(t, ok)
+	require.Equal(t, "env://DB_PASSWORD", field.Value)
+	field, ok = report.Field("Keys")
+	require.True(t, ok)
+	require.Equal(t, []string{"env://API_KEY", "plain", "vault://secret/db#key"}, field.Value)
+	require.NotContains(t, report.String(), "s3cret")
+}
+
+func Test_Load_ResolveSecrets_Off(t *testing.T) {
+	cfg, err := conf.Load[secretOptions](
+		conf.Bytes("yaml", []byte("password: env://X\n")),
+		conf.NoValidation(),
+		conf.Args([]string{}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "env://X", cfg.Password)
+}
+
+func Test_Load_ResolveSecrets_Errors(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Unsetenv("MISSING")
+	os.Setenv("SHORT", "short-secret")
+
+	failing := func(ref string) (string, error) {
+		return "", errors.New("access denied")
+	}
+
+	var tcs = []struct {
+		content  string
+		expected string
+	}{
+		{
+			content:  "password: env://MISSING",
+			expected: "failed to resolve secrets: field Password: failed to resolve the env:// reference: env variable MISSING is not set",
+		},
+		{
+			content:  "password: file:///does/not/exist",
+			expected: "failed to resolve secrets: field Password: failed to resolve the file:// reference: open /does/not/exist: no such file or directory",
+		},
+		{
+			content:  "headers: {auth: \"vault://db\"}",
+			expected: "failed to resolve secrets: field Headers: failed to resolve the vault:// reference: access denied",
+		},
+		{
+			content:  "password: exec://false",
+			expected: "failed to resolve secrets: field Password: failed to resolve the exec:// reference: command false failed: exit status 1",
+		},
+		{
+			content:  "password: env://SHORT",
+			expected: "failed to validate config",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.content, func(t *testing.T) {
+			_, err := conf.Load[secretOptions](
+				conf.Bytes("yaml", []byte(tc.content)),
+				conf.AddResolver("vault", failing),
+				conf.AddResolver("exec", conf.ExecResolver),
+				conf.Args([]string{}),
+			)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expected)
+			require.NotContains(t, err.Error(), "short-secret")
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package conf
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Source is an origin of config values: one of the built-in Defaults, Files, Env and Flags, or a custom source
+// like a database table. Sources merges custom sources with the built-in ones at any priority.
+type Source interface {
+	// Name identifies the source in errors and in the Origin of the values it set
+	Name() string
+	// Load decodes the values of the source into cfg, a pointer to a new config. The fields that Load sets to
+	// other values than their zero value override the sources before it, a source that also sets zero values
+	// is a TreeLoader.
+	Load(cfg any) error
+}
+
+// TreeLoader is a Source that loads a raw tree of maps, slices and scalars, like a decoded JSON document.
+// Load matches its keys to the fields like the keys of a JSON config file, so the fields it names override the
+// sources before it even with their zero value.
+type TreeLoader interface {
+	Source
+	// LoadTree returns the values of the source
+	LoadTree() (map[string]any, error)
+}
+
+// WatchableSource is a Source that tells a Watcher when its values change
+type WatchableSource interface {
+	Source
+	// Watch calls changed whenever the values of the source change until ctx is done
+	Watch(ctx context.Context, changed func()) error
+}
+
+// TreeSource returns a TreeLoader of a function that returns the tree
+func TreeSource(name string, load func() (map[string]any, error)) TreeLoader {
+	return &treeSource{name: name, load: load}
+}
+
+type treeSource struct {
+	name string
+	load func() (map[string]any, error)
+}
+
+func (s *treeSource) Name() string {
+	return s.name
+}
+
+func (s *treeSource) LoadTree() (map[string]any, error) {
+	return s.load()
+}
+
+func (s *treeSource) Load(cfg any) error {
+	tree, err := s.load()
+	if err != nil {
+		return err
+	}
+	return decodeJSONTree(cfg, tree, false)
+}
+
+// defaultSources is the merge order without the Sources option
+var defaultSources = []Source{Defaults, Files, Env, Flags}
+
+// Name returns the name of the built-in source
+func (l Layer) Name() string {
+	return l.String()
+}
+
+// Load loads only the built-in source into cfg with the default options of Load,
+// the command line is only parsed for Flags
+func (l Layer) Load(cfg any) error {
+	opts := []ConfOption{Sources(l), NoValidation()}
+	if l != Flags {
+		opts = append(opts, Args([]string{}))
+	}
+	_, _, err := load(cfg, newConfOptions(opts))
+	return err
+}
+
+// builtin reports whether l is one of the sources that load merges itself
+func (l Layer) builtin() bool {
+	return l >= Defaults && l <= Flags
+}
+
+// loadContext is the state of a load that the built-in sources are loaded from
+type loadContext struct {
+	copts  *confOptions
+	t      reflect.Type
+	fields []field
+	env    map[string]dotenvVar
+	// paths are the resolved config file paths
+	paths    []configPath
+	defaults *layer
+}
+
+// layerSource is a source that load merges itself, in one or more layers
+type layerSource interface {
+	layers(lc *loadContext) ([]*layer, error)
+}
+
+func (l Layer) layers(lc *loadContext) ([]*layer, error) {
+	switch l {
+	case Defaults:
+		return []*layer{lc.defaults}, nil
+	case Files:
+		return loadConfigFiles(lc.copts, lc.t, lc.fields, lc.env, lc.paths...)
+	case Env, Flags:
+		fl, err := loadFlags(lc.copts, lc.t, lc.env, l)
+		if err != nil {
+			return nil, err
+		}
+		return []*layer{fl}, nil
+	}
+	return nil, errors.Errorf("the %s layer is not a built-in source", l)
+}
+
+// sourceLayers loads a source into the layers that are merged in its place
+func sourceLayers(lc *loadContext, s Source) ([]*layer, error) {
+	if ls, ok := s.(layerSource); ok {
+		return ls.layers(lc)
+	}
+	l, err := loadSource(lc, s)
+	if err != nil {
+		return nil, err
+	}
+	return []*layer{l}, nil
+}
+
+// loadSource loads a custom source into a layer of its own. Load is called once, and the fields that hold other
+// values than their zero value are set. The tree of a TreeLoader is decoded into a zero config and a config of
+// sentinels instead, so the fields it sets to their zero value are found too.
+func loadSource(lc *loadContext, s Source) (*layer, error) {
+	var cfg reflect.Value
+	var set map[string]bool
+	if ts, ok := s.(TreeLoader); ok {
+		tree, err := ts.LoadTree()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load source %s", s.Name())
+		}
+		// the tree is decoded with the strictness of the config files
+		cfg, set, err = probeFields(lc.t, lc.fields, func(cfg any) error {
+			return decodeJSONTree(cfg, tree, lc.copts.strict)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load source %s", s.Name())
+		}
+	} else {
+		cfg = reflect.New(lc.t)
+		if err := s.Load(cfg.Interface()); err != nil {
+			return nil, errors.Wrapf(err, "failed to load source %s", s.Name())
+		}
+		set = nonZeroFields(cfg, lc.fields)
+	}
+
+	l := &layer{value: cfg, set: make(map[string]Origin, len(set))}
+	for f := range set {
+		l.set[f] = Origin{Layer: Custom, Name: s.Name()}
+	}
+	return l, nil
+}
+
+// watchSources runs the watches of the sources that support them and calls changed for every change
+func watchSources(ctx context.Context, sources []Source, changed func(), fail func(error)) {
+	for _, s := range sources {
+		ws, ok := s.(WatchableSource)
+		if !ok {
+			continue
+		}
+		go func() {
+			if err := ws.Watch(ctx, changed); err != nil && ctx.Err() == nil {
+				fail(errors.Wrapf(err, "failed to watch source %s", ws.Name()))
+			}
+		}()
+	}
+}
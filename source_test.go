@@ -0,0 +1,232 @@
+package conf_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+// dbSource stands for a custom source like a database table
+type dbSource struct {
+	values  map[string]int
+	err     error
+	changes chan struct{}
+}
+
+func (s *dbSource) Name() string {
+	return "db"
+}
+
+func (s *dbSource) Load(cfg any) error {
+	if s.err != nil {
+		return s.err
+	}
+	c := cfg.(*defaultOptions)
+	c.Int = s.values["int"]
+	c.IntDefault = s.values["intDefault"]
+	return nil
+}
+
+func (s *dbSource) Watch(ctx context.Context, changed func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.changes:
+			changed()
+		}
+	}
+}
+
+func Test_Load_Sources(t *testing.T) {
+	db := &dbSource{values: map[string]int{"int": 7, "intDefault": 8}}
+
+	var tcs = []struct {
+		msg      string
+		sources  []conf.Source
+		args     []string
+		expected func(t *testing.T, cfg *defaultOptions, report *conf.Report)
+	}{
+		{
+			msg:     "custom source between files and flags",
+			sources: []conf.Source{conf.Defaults, conf.Files, db, conf.Env, conf.Flags},
+			args:    []string{"--id=5"},
+			expected: func(t *testing.T, cfg *defaultOptions, report *conf.Report) {
+				require.Equal(t, 7, cfg.Int)
+				require.Equal(t, 5, cfg.IntDefault)
+				require.Equal(t, "asdf", cfg.String)
+
+				field, ok := report.Field("Int")
+				require.True(t, ok)
+				require.Equal(t, conf.Origin{Layer: conf.Custom, Name: "db"}, field.Origin)
+				require.Equal(t, "source db", field.Origin.String())
+				require.Equal(t, []conf.Setting{{Origin: conf.Origin{Layer: conf.Files, Name: "testdata/config.yaml"}, Value: 3}}, field.Overridden)
+			},
+		},
+		{
+			msg:     "custom source wins over the flags",
+			sources: []conf.Source{conf.Defaults, conf.Files, conf.Env, conf.Flags, db},
+			args:    []string{"--id=5"},
+			expected: func(t *testing.T, cfg *defaultOptions, report *conf.Report) {
+				require.Equal(t, 8, cfg.IntDefault)
+			},
+		},
+		{
+			msg:     "sources that are left out are not merged",
+			sources: []conf.Source{conf.Flags},
+			args:    []string{"--i=5"},
+			expected: func(t *testing.T, cfg *defaultOptions, report *conf.Report) {
+				require.Equal(t, 5, cfg.Int)
+				require.Equal(t, 0, cfg.IntDefault)
+				require.Equal(t, "", cfg.String)
+				require.Equal(t, map[string]int{}, cfg.Map)
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.msg, func(t *testing.T) {
+			cfg, report, err := conf.LoadWithReport[defaultOptions](
+				conf.Paths("testdata/config.yaml"),
+				conf.Args(tc.args),
+				conf.Sources(tc.sources...),
+			)
+			require.NoError(t, err)
+			tc.expected(t, cfg, report)
+		})
+	}
+}
+
+func Test_Load_TreeSource(t *testing.T) {
+	tree := conf.TreeSource("tree", func() (map[string]any, error) {
+		return map[string]any{"int": 4, "intDefault": 0, "time": "2s", "map": map[string]any{"a": 1}}, nil
+	})
+
+	cfg, report, err := conf.LoadWithReport[defaultOptions](
+		conf.Paths("testdata/config.yaml"),
+		conf.Args([]string{}),
+		conf.Sources(conf.Defaults, conf.Files, tree, conf.Env, conf.Flags),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 4, cfg.Int)
+	require.Equal(t, 0, cfg.IntDefault)
+	require.Equal(t, 2*time.Second, cfg.Time)
+	require.Equal(t, map[string]int{"a": 1}, cfg.Map)
+	require.Equal(t, "asdf", cfg.String)
+
+	field, ok := report.Field("IntDefault")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Custom, Name: "tree"}, field.Origin)
+}
+
+// portSource sets IntDefault to the zero value, which overrides the default of 1 because it is a TreeLoader
+type portSource struct{}
+
+func (portSource) Name() string {
+	return "port"
+}
+
+func (portSource) Load(cfg any) error {
+	cfg.(*defaultOptions).IntDefault = 0
+	return nil
+}
+
+func (portSource) LoadTree() (map[string]any, error) {
+	return map[string]any{"intDefault": 0}, nil
+}
+
+func Test_Load_Source_ZeroValue(t *testing.T) {
+	cfg, report, err := conf.LoadWithReport[defaultOptions](
+		conf.Args([]string{}),
+		conf.Sources(conf.Defaults, portSource{}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 0, cfg.IntDefault)
+	require.Equal(t, "abc", cfg.StringDefault)
+
+	field, ok := report.Field("IntDefault")
+	require.True(t, ok)
+	require.Equal(t, conf.Origin{Layer: conf.Custom, Name: "port"}, field.Origin)
+
+	field, ok = report.Field("StringDefault")
+	require.True(t, ok)
+	require.Equal(t, conf.Defaults, field.Origin.Layer)
+
+	// the zero values that Load assigns are not told apart from the fields it leaves alone
+	db := &dbSource{values: map[string]int{"int": 2, "intDefault": 0}}
+	cfg, err = conf.Load[defaultOptions](conf.Args([]string{}), conf.Sources(conf.Defaults, db))
+	require.NoError(t, err)
+	require.Equal(t, 2, cfg.Int)
+	require.Equal(t, 1, cfg.IntDefault)
+}
+
+func Test_Layer_Load(t *testing.T) {
+	oldEnv := EnvSnapshot()
+	defer oldEnv.Restore()
+	os.Setenv("TEST_I", "2")
+
+	var cfg envDefaultOptions
+	require.NoError(t, conf.Defaults.Load(&cfg))
+	require.Equal(t, 1, cfg.Int)
+	require.Equal(t, time.Minute, cfg.Time)
+
+	cfg = envDefaultOptions{}
+	require.NoError(t, conf.Env.Load(&cfg))
+	require.Equal(t, 2, cfg.Int)
+	require.Equal(t, time.Duration(0), cfg.Time)
+
+	require.EqualError(t, conf.Custom.Load(&cfg), "invalid sources: the source layer is not a built-in source")
+}
+
+func Test_Load_Source_Errors(t *testing.T) {
+	db := &dbSource{err: errors.New("connection refused")}
+	_, err := conf.Load[defaultOptions](conf.Args([]string{}), conf.Sources(conf.Defaults, db))
+	require.EqualError(t, err, "failed to load source db: connection refused")
+
+	tree := conf.TreeSource("tree", func() (map[string]any, error) {
+		return map[string]any{"int": 4, "nope": 1}, nil
+	})
+	_, err = conf.Load[defaultOptions](conf.Args([]string{}), conf.Sources(tree), conf.Strict())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to load source tree: unknown keys: nope")
+
+	_, err = conf.Load[defaultOptions](conf.Args([]string{}), conf.Sources(conf.Defaults, conf.Custom))
+	require.EqualError(t, err, "invalid sources: the source layer is not a built-in source")
+
+	_, err = conf.Load[defaultOptions](conf.Args([]string{}), conf.Sources(conf.Defaults, conf.Env, conf.Defaults))
+	require.EqualError(t, err, "invalid sources: the default source is listed twice")
+}
+
+func Test_Watch_Source(t *testing.T) {
+	db := &dbSource{values: map[string]int{"int": 1}, changes: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := conf.Watch[defaultOptions](ctx,
+		conf.Args([]string{}),
+		conf.Sources(conf.Defaults, db),
+		conf.PollInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, w.Config().Int)
+
+	updates := make(chan *defaultOptions, 1)
+	w.Subscribe(func(cfg *defaultOptions) {
+		updates <- cfg
+	})
+
+	db.values = map[string]int{"int": 2}
+	db.changes <- struct{}{}
+	select {
+	case cfg := <-updates:
+		require.Equal(t, 2, cfg.Int)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
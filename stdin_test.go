@@ -0,0 +1,57 @@
+package conf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Load_Stdin(t *testing.T) {
+	var tcs = map[string]struct {
+		opts     []conf.ConfOption
+		expected int
+	}{
+		"flag with format prefix": {
+			opts:     []conf.ConfOption{conf.ConfigFlag("conf"), conf.Args([]string{"--conf=yaml:-"}), conf.Stdin(strings.NewReader("int: 4\n"))},
+			expected: 4,
+		},
+		"flag with detected format": {
+			opts:     []conf.ConfOption{conf.ConfigFlag("conf"), conf.Args([]string{"--conf=testdata/config.yaml", "--conf=-"}), conf.Stdin(strings.NewReader(`{"int": 5}`))},
+			expected: 5,
+		},
+		"paths with default format": {
+			opts:     []conf.ConfOption{conf.Paths("-", "testdata/config-empty.yaml"), conf.Args([]string{}), conf.Stdin(strings.NewReader("int = 6\n")), conf.StdinFormat("toml")},
+			expected: 6,
+		},
+		"empty stdin": {
+			opts:     []conf.ConfOption{conf.Paths("-"), conf.Args([]string{}), conf.Stdin(strings.NewReader(""))},
+			expected: 0,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			cfg, report, err := conf.LoadWithReport[defaultOptions](tc.opts...)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, cfg.Int)
+
+			if tc.expected != 0 {
+				field, ok := report.Field("Int")
+				require.True(t, ok)
+				require.Equal(t, conf.Origin{Layer: conf.Files, Name: "<stdin>"}, field.Origin)
+			}
+		})
+	}
+}
+
+func Test_Load_Stdin_Twice(t *testing.T) {
+	_, err := conf.Load[defaultOptions](
+		conf.Paths("-"),
+		conf.ConfigFlag("conf"),
+		conf.Args([]string{"--conf=yaml:-"}),
+		conf.Stdin(strings.NewReader("int: 4\n")),
+	)
+	require.EqualError(t, err, "stdin can only be read once, but - is given as a config file path more than once")
+}
@@ -0,0 +1,116 @@
+package conf_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type strictNestedOptions struct {
+	Timeout string `yaml:"timeout" toml:"timeout" json:"timeout"`
+}
+
+type strictOptions struct {
+	Int    int                 `long:"i" yaml:"int" toml:"int" json:"int"`
+	Nested strictNestedOptions `yaml:"nested" toml:"nested" json:"nested"`
+}
+
+func Test_Load_Strict(t *testing.T) {
+	var tcs = map[string]struct {
+		path        string
+		expectedErr string
+	}{
+		"YAML": {
+			path:        "testdata/config-unknown.yaml",
+			expectedErr: "failed to merge config file testdata/config-unknown.yaml: failed to decode yaml: unknown keys: tiemout (line 2)",
+		},
+		"TOML": {
+			path:        "testdata/config-unknown.toml",
+			expectedErr: "failed to merge config file testdata/config-unknown.toml: failed to decode toml: unknown keys: tiemout",
+		},
+		"JSON": {
+			path:        "testdata/config-unknown.json",
+			expectedErr: "failed to merge config file testdata/config-unknown.json: failed to decode json: unknown keys: tiemout (line 3)",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := conf.Load[defaultOptions](conf.Paths(tc.path), conf.Args([]string{}), conf.Strict())
+			require.EqualError(t, err, tc.expectedErr)
+
+			_, err = conf.Load[defaultOptions](conf.Paths(tc.path), conf.Args([]string{}))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_Load_Strict_Nested(t *testing.T) {
+	var tcs = map[string]struct {
+		data        string
+		expectedErr string
+	}{
+		".yaml": {
+			data:        "int: 1\nnested:\n  timeout: 1s\n  tiemout: 1s\n",
+			expectedErr: "unknown keys: nested.tiemout (line 4)",
+		},
+		".toml": {
+			data:        "int = 1\n[nested]\ntimeout = '1s'\ntiemout = '1s'\n",
+			expectedErr: "unknown keys: nested.tiemout",
+		},
+		".json": {
+			data:        `{"int": 1, "nested": {"timeout": "1s", "tiemout": "1s"}}`,
+			expectedErr: "unknown keys: nested.tiemout",
+		},
+	}
+
+	for ext, tc := range tcs {
+		t.Run(ext, func(t *testing.T) {
+			_, err := conf.Load[strictOptions](conf.Bytes(ext, []byte(tc.data)), conf.Strict(), conf.Args([]string{}))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
+
+func Test_Load_Strict_DefaultDecoders(t *testing.T) {
+	yamlDecoder := conf.DefaultDecoders[".yaml"]
+	defer func() { conf.DefaultDecoders[".yaml"] = yamlDecoder }()
+
+	var decoded bool
+	conf.DefaultDecoders[".yaml"] = func(cfg any, r io.Reader) error {
+		decoded = true
+		return conf.YAMLDecoder(cfg, r)
+	}
+
+	cfg, err := conf.Load[strictOptions](conf.Bytes(".yaml", []byte("int: 1\ntiemout: 1s\n")), conf.Args([]string{}))
+	require.NoError(t, err)
+	require.True(t, decoded)
+	require.Equal(t, 1, cfg.Int)
+
+	// the built-in decoder that the replacement calls with its reader follows Strict
+	_, err = conf.Load[strictOptions](conf.Bytes(".yaml", []byte("int: 1\ntiemout: 1s\n")), conf.Strict(), conf.Args([]string{}))
+	require.EqualError(t, err, "failed to merge config file <bytes>: failed to decode yaml: unknown keys: tiemout (line 2)")
+}
+
+func Test_Load_Strict_AddDecoder(t *testing.T) {
+	conf.DefaultDecoders[".conf"] = conf.YAMLDecoder
+	defer delete(conf.DefaultDecoders, ".conf")
+
+	data := []byte("int: 1\ntiemout: 1s\n")
+	for name, opts := range map[string][]conf.ConfOption{
+		"DefaultDecoders": {},
+		"AddDecoder":      {conf.AddDecoder(".conf", conf.YAMLDecoder)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := conf.Load[strictOptions](append(opts, conf.Bytes(".conf", data), conf.Args([]string{}))...)
+			require.NoError(t, err)
+
+			_, err = conf.Load[strictOptions](append(opts, conf.Bytes(".conf", data), conf.Strict(), conf.Args([]string{}))...)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "failed to decode yaml: unknown keys: tiemout (line 2)")
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package conf
+
+import (
+	"context"
+	"crypto/sha256"
+	stderr "errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Watcher keeps a config loaded and reloads it whenever one of its config files changes
+type Watcher[T any] struct {
+	copts *confOptions
+	// changed receives a value when a WatchableSource reports a change
+	changed chan struct{}
+
+	mu      sync.Mutex
+	cfg     *T
+	subs    map[int]func(*T)
+	nextSub int
+	onError func(error)
+}
+
+// Watch loads the config like Load and then polls the config files from Paths, OptionalPaths and the ConfigFlag value.
+// URLs are polled with conditional requests, so an unchanged config costs a 304 Not Modified response.
+// Consul keys are watched with blocking queries and the sources that implement WatchableSource with their Watch method.
+// When one of them changes the whole config is loaded again and handed to the subscribers.
+// If the new config fails to load or validate, the last good config is kept and the error is passed to OnError.
+// Watching stops when ctx is done.
+func Watch[T any](ctx context.Context, opts ...ConfOption) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		copts:   newConfOptions(opts),
+		changed: make(chan struct{}, 1),
+		subs:    make(map[int]func(*T)),
+	}
+
+	cfg := new(T)
+	_, paths, err := load(cfg, w.copts)
+	if err != nil {
+		return nil, err
+	}
+	w.cfg = cfg
+
+	for _, path := range w.copts.paths {
+		if c, ok := consulOf(path); ok {
+			go c.watch(ctx, w.copts, w.notify, w.fail)
+		}
+	}
+	watchSources(ctx, w.copts.sourceOrder(), w.notify, w.fail)
+	go w.run(ctx, paths, fileStamps(w.copts, paths))
+
+	return w, nil
+}
+
+// Config returns the last config that loaded successfully
+func (w *Watcher[T]) Config() *T {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cfg
+}
+
+// Subscribe registers f to be called with every reloaded config, the returned func unregisters it
+func (w *Watcher[T]) Subscribe(f func(*T)) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextSub
+	w.nextSub++
+	w.subs[id] = f
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// OnError registers f to be called when a reload fails
+func (w *Watcher[T]) OnError(f func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = f
+}
+
+func (w *Watcher[T]) run(ctx context.Context, paths []configPath, stamps [sha256.Size]byte) {
+	ticker := time.NewTicker(w.copts.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := fileStamps(w.copts, paths)
+			if current == stamps {
+				continue
+			}
+			stamps = current
+		case <-w.changed:
+			// the stamps are taken before the reload, so the next poll does not reload the same change again
+			stamps = fileStamps(w.copts, paths)
+		}
+
+		cfg := new(T)
+		_, newPaths, err := load(cfg, w.copts)
+		if err != nil {
+			w.fail(errors.Wrap(err, "failed to reload config"))
+			continue
+		}
+		if !samePaths(paths, newPaths) {
+			paths = newPaths
+			stamps = fileStamps(w.copts, paths)
+		}
+
+		w.mu.Lock()
+		w.cfg = cfg
+		subs := make([]func(*T), 0, len(w.subs))
+		for _, f := range w.subs {
+			subs = append(subs, f)
+		}
+		w.mu.Unlock()
+
+		for _, f := range subs {
+			f(cfg)
+		}
+	}
+}
+
+// notify makes the Watcher reload, changes that arrive before the reload starts are handled by the same reload
+func (w *Watcher[T]) notify() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher[T]) fail(err error) {
+	w.mu.Lock()
+	onError := w.onError
+	w.mu.Unlock()
+
+	if onError != nil {
+		onError(err)
+	}
+}
+
+// fileStamps hashes the contents of the config files, missing files and read errors are part of the stamp
+// so that creating an optional file or fixing its permissions triggers a reload too
+func fileStamps(copts *confOptions, paths []configPath) [sha256.Size]byte {
+	h := sha256.New()
+	for _, path := range paths {
+		var data []byte
+		var err error
+		if c, ok := consulOf(path); ok {
+			data, err = c.cached(copts)
+		} else {
+			data, err = readConfigFile(copts, path)
+		}
+		switch {
+		case stderr.Is(err, fs.ErrNotExist):
+			h.Write([]byte{0})
+		case err != nil:
+			h.Write([]byte{1})
+			h.Write([]byte(err.Error()))
+		default:
+			h.Write([]byte{2})
+			sum := sha256.Sum256(data)
+			h.Write(sum[:])
+		}
+	}
+
+	var stamp [sha256.Size]byte
+	copy(stamp[:], h.Sum(nil))
+	return stamp
+}
+
+func samePaths(a, b []configPath) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package conf_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("int: 1\n"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := conf.Watch[defaultOptions](ctx, conf.Paths(path), conf.Args([]string{}), conf.PollInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, 1, w.Config().Int)
+
+	updates := make(chan *defaultOptions, 1)
+	w.Subscribe(func(cfg *defaultOptions) {
+		updates <- cfg
+	})
+	errs := make(chan error, 1)
+	w.OnError(func(err error) {
+		errs <- err
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("int: 2\nintDefault: 0\n"), 0o644))
+	select {
+	case cfg := <-updates:
+		require.Equal(t, 2, cfg.Int)
+		require.Equal(t, 0, cfg.IntDefault)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+	require.Equal(t, 2, w.Config().Int)
+
+	require.NoError(t, os.WriteFile(path, []byte("int: [\n"), 0o644))
+	select {
+	case err := <-errs:
+		require.Contains(t, err.Error(), "failed to reload config")
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload error was not reported")
+	}
+	require.Equal(t, 2, w.Config().Int)
+}
+
+func Test_Watch_OptionalPathCreated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := conf.Watch[defaultOptions](ctx, conf.OptionalPaths(path), conf.Args([]string{}), conf.PollInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, onlyDefaults, w.Config())
+
+	updates := make(chan *defaultOptions, 1)
+	w.Subscribe(func(cfg *defaultOptions) {
+		updates <- cfg
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("string: created\n"), 0o644))
+	select {
+	case cfg := <-updates:
+		require.Equal(t, "created", cfg.String)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
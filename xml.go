@@ -0,0 +1,284 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// xmlElement is an element of an XML document
+type xmlElement struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlElement
+	text     string
+	line     int
+}
+
+func parseXML(r io.Reader) (*xmlElement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var root *xmlElement
+	var stack []*xmlElement
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			line := bytes.Count(data[:d.InputOffset()], []byte("\n")) + 1
+			e := &xmlElement{name: tok.Name.Local, attrs: tok.Attr, line: line}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			} else if len(bytes.TrimSpace(tok)) > 0 {
+				return nil, errors.Errorf("unexpected text %q outside of the root element", bytes.TrimSpace(tok))
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, io.EOF
+	}
+	return root, nil
+}
+
+// decodeXML decodes the children of the root element into cfg.
+// Elements and attributes are matched to fields by their xml tags, or by the keys the YAML decoder uses.
+// Repeated elements fill slices. The children of a map element are its entries, either by their element names
+// or as <entry key="name">value</entry> elements.
+func decodeXML(cfg any, r io.Reader, strict bool) error {
+	root, err := parseXML(r)
+	if err != nil {
+		return err
+	}
+
+	w := &xmlWalker{strict: strict}
+	n := w.walk(root, reflect.TypeOf(cfg), "")
+	if len(w.unknown) > 0 {
+		sort.SliceStable(w.unknown, func(i, j int) bool { return w.unknown[i].line < w.unknown[j].line })
+		keys := make([]string, len(w.unknown))
+		for i, u := range w.unknown {
+			keys[i] = fmt.Sprintf("%s (line %d)", u.key, u.line)
+		}
+		return unknownKeysError(keys)
+	}
+	return n.Decode(cfg)
+}
+
+// xmlWalker converts an XML tree into a YAML tree with the keys of the fields of the type it decodes into
+type xmlWalker struct {
+	strict  bool
+	unknown []xmlUnknown
+}
+
+type xmlUnknown struct {
+	key  string
+	line int
+}
+
+func (w *xmlWalker) walk(e *xmlElement, t reflect.Type, path string) *yaml.Node {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	pt := reflect.PtrTo(t)
+	if pt.Implements(yamlUnmarshalerType) || pt.Implements(textUnmarshalerType) {
+		return xmlScalar(e.text, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return w.walkStruct(e, t, path)
+	case reflect.Map:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, c := range e.children {
+			key := c.name
+			if c.name == "entry" {
+				if k, ok := xmlAttr(c, "key"); ok {
+					key = k
+					c = withoutAttr(c, "key")
+				}
+			}
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, w.walk(c, t.Elem(), keyPath(path, key)))
+		}
+		return n
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return xmlScalar(e.text, t)
+		}
+		// a single element of a slice field
+		return &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{w.walk(e, t.Elem(), path)}}
+	}
+	return xmlScalar(e.text, t)
+}
+
+func (w *xmlWalker) walkStruct(e *xmlElement, t reflect.Type, path string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	used := make(map[*xmlElement]bool)
+	usedAttrs := make(map[string]bool)
+
+	for _, f := range xmlFields(t) {
+		if !f.attr {
+			var matches []*xmlElement
+			for _, c := range e.children {
+				if c.name == f.xmlName {
+					matches = append(matches, c)
+					used[c] = true
+				}
+			}
+			if len(matches) > 0 {
+				n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.key}, w.walkField(matches, f.typ, keyPath(path, f.xmlName)))
+				continue
+			}
+		}
+		if v, ok := xmlAttr(e, f.xmlName); ok && (f.attr || !f.elem) {
+			usedAttrs[f.xmlName] = true
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.key}, xmlScalar(v, f.typ))
+		}
+	}
+
+	if w.strict {
+		for _, a := range e.attrs {
+			if !usedAttrs[a.Name.Local] && a.Name.Space == "" {
+				w.unknown = append(w.unknown, xmlUnknown{key: keyPath(path, "@"+a.Name.Local), line: e.line})
+			}
+		}
+		for _, c := range e.children {
+			if !used[c] {
+				w.unknown = append(w.unknown, xmlUnknown{key: keyPath(path, c.name), line: c.line})
+			}
+		}
+	}
+	return n
+}
+
+// walkField converts the elements that match a field, repeated elements become the items of a slice
+func (w *xmlWalker) walkField(matches []*xmlElement, t reflect.Type, path string) *yaml.Node {
+	ft := t
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	isSlice := (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array) && ft.Elem().Kind() != reflect.Uint8 &&
+		!reflect.PtrTo(ft).Implements(yamlUnmarshalerType) && !reflect.PtrTo(ft).Implements(textUnmarshalerType)
+	if !isSlice {
+		return w.walk(matches[len(matches)-1], t, path)
+	}
+
+	n := &yaml.Node{Kind: yaml.SequenceNode}
+	for i, m := range matches {
+		n.Content = append(n.Content, w.walk(m, ft.Elem(), fmt.Sprintf("%s[%d]", path, i)))
+	}
+	return n
+}
+
+// xmlScalar returns a plain scalar that yaml.v3 resolves against the type it decodes into, strings stay strings
+func xmlScalar(text string, t reflect.Type) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(text)}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String {
+		n.Tag = "!!str"
+	}
+	return n
+}
+
+func xmlAttr(e *xmlElement, name string) (string, bool) {
+	for _, a := range e.attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func withoutAttr(e *xmlElement, name string) *xmlElement {
+	c := *e
+	c.attrs = nil
+	for _, a := range e.attrs {
+		if a.Name.Local != name {
+			c.attrs = append(c.attrs, a)
+		}
+	}
+	return &c
+}
+
+var xmlNameType = reflect.TypeOf(xml.Name{})
+
+type xmlField struct {
+	// key is the YAML key of the field and xmlName the name of its element or attribute
+	key     string
+	xmlName string
+	// attr is set for fields tagged with ,attr and elem for fields with other xml tags
+	attr bool
+	elem bool
+	typ  reflect.Type
+}
+
+// xmlFields returns the fields of a struct that the YAML decoder sets, following inlined structs
+func xmlFields(t reflect.Type) []xmlField {
+	var fields []xmlField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		key, inline, skip := yamlFieldName(sf)
+		if skip || sf.Tag.Get("xml") == "-" || sf.Type == xmlNameType {
+			continue
+		}
+		if inline {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, xmlFields(ft)...)
+			}
+			continue
+		}
+
+		f := xmlField{key: key, xmlName: key, typ: sf.Type}
+		if tag, ok := sf.Tag.Lookup("xml"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				f.xmlName = parts[0]
+			}
+			for _, flag := range parts[1:] {
+				if flag == "attr" {
+					f.attr = true
+				}
+			}
+			f.elem = !f.attr
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
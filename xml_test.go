@@ -0,0 +1,73 @@
+package conf_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chai/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type xmlServerOptions struct {
+	Host    string        `yaml:"host" xml:"hostname"`
+	Port    int           `yaml:"port" xml:"port,attr"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type xmlOptions struct {
+	Name    string                      `yaml:"name"`
+	Servers []xmlServerOptions          `yaml:"servers" xml:"server"`
+	Limits  map[string]int              `yaml:"limits"`
+	ByName  map[string]xmlServerOptions `yaml:"byName"`
+	Tags    []string                    `yaml:"tags"`
+}
+
+func Test_XMLDecoder(t *testing.T) {
+	data := `<config name="a">
+  <server port="80"><hostname>h1</hostname><timeout>1s</timeout></server>
+  <server port="81"><hostname>h2</hostname></server>
+  <limits><entry key="a b">1</entry><c>2</c></limits>
+  <byName><entry key="x" port="90"><hostname>h3</hostname></entry></byName>
+  <tags>t1</tags>
+</config>`
+
+	cfg := new(xmlOptions)
+	err := conf.XMLDecoder(cfg, strings.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, &xmlOptions{
+		Name: "a",
+		Servers: []xmlServerOptions{
+			{Host: "h1", Port: 80, Timeout: time.Second},
+			{Host: "h2", Port: 81},
+		},
+		Limits: map[string]int{"a b": 1, "c": 2},
+		ByName: map[string]xmlServerOptions{"x": {Host: "h3", Port: 90}},
+		Tags:   []string{"t1"},
+	}, cfg)
+}
+
+func Test_XMLDecoder_NullStrings(t *testing.T) {
+	cfg := new(xmlOptions)
+	err := conf.XMLDecoder(cfg, strings.NewReader(`<config name="~"><tags>null</tags><byName><x><hostname>~</hostname></x></byName></config>`))
+	require.NoError(t, err)
+	require.Equal(t, &xmlOptions{
+		Name:   "~",
+		ByName: map[string]xmlServerOptions{"x": {Host: "~"}},
+		Tags:   []string{"null"},
+	}, cfg)
+}
+
+func Test_XMLDecoder_Errors(t *testing.T) {
+	err := conf.XMLDecoder(new(xmlOptions), strings.NewReader("<config><name>a</config>"))
+	require.EqualError(t, err, "failed to decode xml: XML syntax error on line 1: element <name> closed by </config>")
+
+	err = conf.XMLDecoder(new(xmlOptions), strings.NewReader("<config>\n<server port=\"x\"/>\n</config>"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot unmarshal !!str `x` into int")
+
+	data := "<config nmae=\"a\">\n<server><host>h</host></server>\n<byName><entry key=\"x\" prot=\"1\"/></byName>\n</config>"
+	_, err = conf.Load[xmlOptions](conf.Bytes(".xml", []byte(data)), conf.Strict(), conf.Args([]string{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to decode xml: unknown keys: @nmae (line 1), server[0].host (line 2), byName.x.@prot (line 3)")
+}